@@ -0,0 +1,187 @@
+package main
+
+import (
+	"context"
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io/fs"
+	"log"
+	"os"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/felipeblassioli/vwap/pkg/coinbase"
+	"github.com/felipeblassioli/vwap/pkg/vwap"
+)
+
+// candleFlushDelay is how long after the end of its interval a candle is
+// closed by the clock rather than by a trade. It leaves room for trades
+// that are in flight and for clock skew between Coinbase and us.
+const candleFlushDelay = time.Second
+
+// runCandleBuilder receives coinbase's Matches feed updates via `updates`
+// channel parameter, aggregates them into candles of the given interval
+// and sends the closed candles downstream. On shutdown, the candle being
+// built is closed and sent downstream too.
+func runCandleBuilder(
+	ctx context.Context,
+	updates <-chan coinbase.Match,
+	candles chan<- vwap.Candle,
+	interval time.Duration,
+) error {
+	b, err := vwap.NewCandleBuilder(interval)
+	if err != nil {
+		return err
+	}
+
+	ticker := time.NewTicker(candleFlushDelay)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			if c := b.Drain(); c != nil {
+				candles <- *c
+			}
+			return ctx.Err()
+		case now := <-ticker.C:
+			if c := b.Flush(now.Add(-candleFlushDelay)); c != nil {
+				candles <- *c
+			}
+		case m := <-updates:
+			c, err := b.Update(vwap.Trade{
+				Price: m.Price,
+				Size:  m.Size,
+				Time:  m.Time,
+			})
+			if err != nil {
+				return err
+			}
+			if c != nil {
+				candles <- *c
+			}
+		}
+	}
+}
+
+// runCandleSink receives closed candles from upstream and writes them with
+// the given function. On shutdown, it writes the candles left upstream until
+// the candles channel is closed.
+func runCandleSink(
+	ctx context.Context,
+	candles <-chan vwap.Candle,
+	product string,
+	write func(product string, c vwap.Candle) error,
+) error {
+	for {
+		select {
+		case <-ctx.Done():
+			for c := range candles {
+				if err := write(product, c); err != nil {
+					return err
+				}
+			}
+			return ctx.Err()
+		case c := <-candles:
+			if err := write(product, c); err != nil {
+				return err
+			}
+		}
+	}
+}
+
+// printCandle writes a candle to stdout.
+func printCandle(product string, c vwap.Candle) error {
+	//nolint:forbidigo // Printer purpose is printing to stdout
+	log.Printf("%s:  %s %s O=%s H=%s L=%s C=%s V=%s VWAP=%s n=%d",
+		product,
+		c.Start.Format(time.RFC3339),
+		c.Interval,
+		c.Open.Text('f', -1),
+		c.High.Text('f', -1),
+		c.Low.Text('f', -1),
+		c.Close.Text('f', -1),
+		c.Volume.Text('f', -1),
+		c.VWAP.Text('f', numPrecDigits),
+		c.Count,
+	)
+
+	return nil
+}
+
+// candleCSVHeader is written to new CSV files exported by csvCandleWriter.
+var candleCSVHeader = []string{
+	"product", "start", "interval", "open", "high", "low", "close", "volume", "vwap", "count",
+}
+
+// csvCandleWriter appends the candles of every product to a CSV file.
+type csvCandleWriter struct {
+	mu sync.Mutex
+	f  *os.File
+	w  *csv.Writer
+}
+
+// newCSVCandleWriter opens the CSV file at path for appending, writing the
+// header if the file is new.
+func newCSVCandleWriter(path string) (*csvCandleWriter, error) {
+	_, err := os.Stat(path)
+	isNew := errors.Is(err, fs.ErrNotExist)
+
+	//nolint:gomnd // rw-r--r--
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0o644)
+	if err != nil {
+		return nil, fmt.Errorf("candles export: %w", err)
+	}
+
+	cw := &csvCandleWriter{f: f, w: csv.NewWriter(f)}
+	if isNew {
+		if err := cw.w.Write(candleCSVHeader); err != nil {
+			f.Close()
+			return nil, fmt.Errorf("candles export: %w", err)
+		}
+	}
+
+	return cw, nil
+}
+
+// write appends a candle to the CSV file, flushing it right away so that
+// the file can be followed while the command-line application runs.
+func (cw *csvCandleWriter) write(product string, c vwap.Candle) error {
+	cw.mu.Lock()
+	defer cw.mu.Unlock()
+
+	err := cw.w.Write([]string{
+		product,
+		c.Start.Format(time.RFC3339),
+		c.Interval.String(),
+		c.Open.Text('f', -1),
+		c.High.Text('f', -1),
+		c.Low.Text('f', -1),
+		c.Close.Text('f', -1),
+		c.Volume.Text('f', -1),
+		c.VWAP.Text('f', numPrecDigits),
+		strconv.Itoa(c.Count),
+	})
+	if err != nil {
+		return fmt.Errorf("candles export: %w", err)
+	}
+	cw.w.Flush()
+
+	return cw.w.Error()
+}
+
+// Close closes the CSV file.
+func (cw *csvCandleWriter) Close() error {
+	cw.mu.Lock()
+	defer cw.mu.Unlock()
+
+	cw.w.Flush()
+	if err := cw.w.Error(); err != nil {
+		cw.f.Close()
+		return err
+	}
+
+	return cw.f.Close()
+}
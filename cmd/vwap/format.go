@@ -0,0 +1,87 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/felipeblassioli/vwap/pkg/vwap"
+)
+
+// numPrecDigits is the number of digits printed for prices, matching
+// vwap.Result's String.
+const numPrecDigits = 16
+
+// windowResults are the results of every window of a calculator.
+type windowResults struct {
+	rs           []vwap.Result
+	windowWidths []int
+}
+
+// String formats a single window as by formatResult, multiple windows are
+// prefixed by their width.
+func (w windowResults) String() string {
+	if len(w.rs) == 1 {
+		return formatResult(w.rs[0])
+	}
+
+	parts := make([]string, 0, len(w.rs))
+	for i, r := range w.rs {
+		parts = append(parts, fmt.Sprintf("w%d=%s", w.windowWidths[i], formatResult(r)))
+	}
+
+	return strings.Join(parts, " ")
+}
+
+// indicatorValues are the values of a set of indicators.
+type indicatorValues []vwap.Value
+
+// String formats the values as name=value pairs.
+func (vs indicatorValues) String() string {
+	parts := make([]string, 0, len(vs))
+	for _, v := range vs {
+		parts = append(parts, v.Name+"="+v.Value.Text('f', numPrecDigits))
+	}
+
+	return strings.Join(parts, " ")
+}
+
+// formatResult formats the VWAP followed by its bands, side split,
+// percentiles and range, if any.
+func formatResult(r vwap.Result) string {
+	var sb strings.Builder
+	sb.WriteString(r.String())
+	for _, b := range r.Bands {
+		fmt.Fprintf(&sb, " %gσ=[%s, %s]",
+			b.K,
+			b.Lower.Text('f', numPrecDigits),
+			b.Upper.Text('f', numPrecDigits),
+		)
+	}
+	if r.Buy != nil {
+		fmt.Fprintf(&sb, " buy=%s sell=%s imbalance=%s",
+			r.Buy.VWAP.Text('f', numPrecDigits),
+			r.Sell.VWAP.Text('f', numPrecDigits),
+			r.Imbalance.Text('f', 4),
+		)
+	}
+	for _, p := range r.Percentiles {
+		fmt.Fprintf(&sb, " p%g=%s", p.P, p.Price.Text('f', numPrecDigits))
+	}
+	if r.High != nil {
+		fmt.Fprintf(&sb, " low=%s high=%s",
+			r.Low.Text('f', numPrecDigits),
+			r.High.Text('f', numPrecDigits),
+		)
+	}
+
+	return sb.String()
+}
+
+// profileOutput is the volume profile of a product.
+type profileOutput vwap.Profile
+
+// String formats the profile as by vwap.Profile's String, prefixed to tell
+// it apart from the VWAP.
+func (p profileOutput) String() string {
+	return "profile " + vwap.Profile(p).String()
+}
@@ -2,12 +2,13 @@ package main
 
 import (
 	"context"
-	"flag"
+	"fmt"
 	"log"
+	"math/big"
 	"os"
 	"os/signal"
-	"strings"
 	"syscall"
+	"time"
 
 	"golang.org/x/sync/errgroup"
 
@@ -65,16 +66,157 @@ func runMatchesWatcher(
 	}
 }
 
+// updater feeds a trade to the configured calculators and returns their
+// output.
+type updater func(vwap.Trade) (fmt.Stringer, error)
+
+// snapshotter persists the state of the calculators of an updater.
+type snapshotter func() error
+
+// profiler returns the volume profile of a product.
+type profiler func() vwap.Profile
+
+// vwapStage holds the functions run by the vwap stage of a pipeline.
+type vwapStage struct {
+	update updater
+	// snapshot is nil unless a state directory is configured.
+	snapshot snapshotter
+	// profile is nil unless the volume profile is enabled.
+	profile profiler
+	// vwap returns the current VWAP, of the first window if there are
+	// multiple windows. It is nil for indicators.
+	vwap func() *big.Float
+}
+
+// newVWAPStage returns a vwapStage whose updater is backed by the
+// vwap.Indicators configured, a vwap.Calculator for a single window width,
+// or a vwap.MultiCalculator for multiple window widths.
+//
+// If a state directory is configured, the vwap.Calculator is restored from
+// the product's snapshot and the snapshotter saves it.
+//
+// If the volume profile is enabled, the profiler returns the profile of the
+// vwap.Calculator's window, or of every trade since startup for a session
+// profile.
+func newVWAPStage(cfg config, product string) (vwapStage, error) {
+	s, err := newCalculatorStage(cfg, product)
+	if err != nil || !cfg.profileSession {
+		return s, err
+	}
+
+	session, err := vwap.NewVolumeProfile(cfg.profileTick)
+	if err != nil {
+		return vwapStage{}, err
+	}
+	update := s.update
+	s.update = func(t vwap.Trade) (fmt.Stringer, error) {
+		if err := session.Update(t); err != nil {
+			return nil, err
+		}
+		return update(t)
+	}
+	s.profile = session.Profile
+
+	return s, nil
+}
+
+// newCalculatorStage returns the vwapStage of newVWAPStage, without the
+// session profile.
+func newCalculatorStage(cfg config, product string) (vwapStage, error) {
+	if len(cfg.indicators) > 0 {
+		is, err := cfg.newIndicators()
+		if err != nil {
+			return vwapStage{}, err
+		}
+		return vwapStage{update: func(t vwap.Trade) (fmt.Stringer, error) {
+			vs, err := is.Update(t)
+			return indicatorValues(vs), err
+		}}, nil
+	}
+
+	if len(cfg.windowWidths) > 1 {
+		calc, err := vwap.NewMultiCalculator(cfg.windowWidths...)
+		if err != nil {
+			return vwapStage{}, err
+		}
+		return vwapStage{
+			update: func(t vwap.Trade) (fmt.Stringer, error) {
+				rs, err := calc.Update(t)
+				return windowResults{rs, cfg.windowWidths}, err
+			},
+			vwap: func() *big.Float {
+				return calc.Value()[0].VWAP
+			},
+		}, nil
+	}
+
+	var (
+		s    vwapStage
+		calc *vwap.Calculator
+		err  error
+	)
+	if cfg.stateDir != "" {
+		path := snapshotPath(cfg.stateDir, product)
+		calc, err = restoreSnapshot(path, cfg.snapshotMaxAge, cfg.windowWidths[0], cfg.calculatorOptions())
+		if err != nil {
+			return vwapStage{}, err
+		}
+		s.snapshot = func() error {
+			return saveSnapshot(path, calc)
+		}
+	}
+	if calc == nil {
+		calc, err = vwap.NewCalculator(cfg.windowWidths[0], cfg.calculatorOptions()...)
+		if err != nil {
+			return vwapStage{}, err
+		}
+	}
+
+	s.update = func(t vwap.Trade) (fmt.Stringer, error) {
+		r, err := calc.Update(t)
+		return windowResults{[]vwap.Result{r}, cfg.windowWidths}, err
+	}
+	if cfg.profileTick != "" && !cfg.profileSession {
+		s.profile = calc.VolumeProfile
+	}
+	s.vwap = func() *big.Float {
+		return calc.Value().VWAP
+	}
+
+	return s, nil
+}
+
 // runVWAPCalculator receives coinbase's Matches feed updates via `updates`
 // channel parameter, calculates the VWAP and send the result to the printer.
+//
+// If the stage has a snapshotter, it is called every snapshotInterval and
+// once more when the context is cancelled. If it has a profiler, the volume
+// profile is sent to the printer every profileInterval.
+//
+// If legs is not nil, the VWAP is also sent to it after every update.
 func runVWAPCalculator(
 	ctx context.Context,
 	updates <-chan coinbase.Match,
-	printer chan<- string,
-	windowWidth int,
+	printer chan<- fmt.Stringer,
+	legs chan<- legVWAP,
+	stage vwapStage,
+	snapshotInterval time.Duration,
+	profileInterval time.Duration,
 	name string,
 ) error {
-	calc := vwap.NewCalculator(windowWidth)
+	// A nil channel never fires, which disables snapshots and profiles.
+	var tick, profileTick <-chan time.Time
+	if stage.snapshot != nil {
+		ticker := time.NewTicker(snapshotInterval)
+		defer ticker.Stop()
+		tick = ticker.C
+	}
+	if stage.profile != nil {
+		ticker := time.NewTicker(profileInterval)
+		defer ticker.Stop()
+		profileTick = ticker.C
+	}
+
 	for {
 		select {
 		case <-ctx.Done():
@@ -82,96 +224,361 @@ func runVWAPCalculator(
 				//nolint:forbidigo // Removed by the compiler
 				log.Println("Stopping vwap.Calculator updates", name)
 			}
+			if stage.snapshot != nil {
+				if err := stage.snapshot(); err != nil {
+					return err
+				}
+			}
 			return ctx.Err()
+		case <-tick:
+			if err := stage.snapshot(); err != nil {
+				return err
+			}
+		case <-profileTick:
+			printer <- profileOutput(stage.profile())
 		case m := <-updates:
-			//nolint:gocritic // Shadowing the package in this scope is ok for clarity
-			vwap, err := calc.Update(m.Price, m.Size)
+			out, err := stage.update(toTrade(m))
 			if err != nil {
 				return err
 			}
-			printer <- vwap
+			printer <- out
+			if legs != nil {
+				select {
+				case <-ctx.Done():
+				case legs <- legVWAP{name, stage.vwap()}:
+				}
+			}
+		}
+	}
+}
+
+// toTrade converts a match into a vwap.Trade.
+func toTrade(m coinbase.Match) vwap.Trade {
+	return vwap.Trade{
+		Price:   m.Price,
+		Size:    m.Size,
+		Time:    m.Time,
+		Side:    takerSide(m),
+		TradeID: m.TradeID,
+	}
+}
+
+// runOutlierFilter receives coinbase's Matches feed updates via `updates`
+// channel parameter and forwards downstream the ones the filter accepts.
+// Rejected matches are logged along with the filter's counters.
+func runOutlierFilter(
+	ctx context.Context,
+	updates <-chan coinbase.Match,
+	accepted chan<- coinbase.Match,
+	f *vwap.OutlierFilter,
+	product string,
+) error {
+	for {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case m := <-updates:
+			r, err := f.Check(toTrade(m))
+			if err != nil {
+				return err
+			}
+			if r != nil {
+				stats := f.Stats()
+				//nolint:forbidigo // The log of rejected trades goes along with the output
+				log.Printf("%s: rejected outlier trade %d: %s (accepted=%d rejected=%d)",
+					product, m.TradeID, r, stats.Accepted, stats.Rejected)
+				continue
+			}
+			accepted <- m
+		}
+	}
+}
+
+// runReorderer receives coinbase's Matches feed updates via `updates`
+// channel parameter and forwards them downstream ordered by time, holding
+// them for up to lateness. Matches arriving too late to be forwarded in
+// order are dropped, and logged if report is set.
+func runReorderer(
+	ctx context.Context,
+	updates <-chan coinbase.Match,
+	ordered chan<- coinbase.Match,
+	lateness time.Duration,
+	report bool,
+	product string,
+) error {
+	r := coinbase.NewReorderer(lateness)
+
+	// Matches are also released by the clock, in case the feed goes quiet.
+	ticker := time.NewTicker(lateness)
+	defer ticker.Stop()
+
+	for {
+		var ms []coinbase.Match
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-ticker.C:
+			ms = r.Flush()
+		case m := <-updates:
+			var err error
+			ms, err = r.Push(m)
+			if err != nil && report {
+				//nolint:forbidigo // The log of late trades goes along with the output
+				log.Printf("%s: dropped %s (late=%d)", product, err, r.Late())
+			}
 		}
+		for _, m := range ms {
+			ordered <- m
+		}
+	}
+}
+
+// goReorderer starts the reordering stage of a pipeline if a lateness
+// tolerance is configured:
+//
+//	chan coinbase.Match -> chan coinbase.Match
+//	matches             -> ordered
+//
+// It returns the channel of the matches for the next stage.
+func goReorderer(
+	ctx context.Context,
+	g *errgroup.Group,
+	cfg config,
+	product string,
+	matches <-chan coinbase.Match,
+) <-chan coinbase.Match {
+	if cfg.lateness == 0 {
+		return matches
+	}
+
+	ordered := make(chan coinbase.Match, cfg.bufSize())
+	g.Go(func() error {
+		defer close(ordered)
+		return runReorderer(ctx, matches, ordered, cfg.lateness, cfg.lateMatches == lateReport, product)
+	})
+
+	return ordered
+}
+
+// goOutlierFilter starts the outlier filter stage of a pipeline if a filter
+// is given:
+//
+//	chan coinbase.Match -> chan coinbase.Match
+//	matches             -> accepted
+//
+// It returns the channel of the matches for the next stage.
+func goOutlierFilter(
+	ctx context.Context,
+	g *errgroup.Group,
+	cfg config,
+	product string,
+	f *vwap.OutlierFilter,
+	matches <-chan coinbase.Match,
+) <-chan coinbase.Match {
+	if f == nil {
+		return matches
+	}
+
+	accepted := make(chan coinbase.Match, cfg.bufSize())
+	g.Go(func() error {
+		defer close(accepted)
+		return runOutlierFilter(ctx, matches, accepted, f, product)
+	})
+
+	return accepted
+}
+
+// takerSide returns the aggressor side of a match.
+func takerSide(m coinbase.Match) vwap.Side {
+	switch m.TakerSide() {
+	case "buy":
+		return vwap.Buy
+	case "sell":
+		return vwap.Sell
+	default:
+		return vwap.SideUnknown
 	}
 }
 
-func runPrinter(ctx context.Context, printer chan string, product string) error {
+// runPrinter receives VWAP results from upstream and formats them to stdout.
+func runPrinter(ctx context.Context, printer chan fmt.Stringer, product string) error {
 	for {
 		select {
 		case <-ctx.Done():
 			return ctx.Err()
-		case s := <-printer:
+		case out := <-printer:
 			//nolint:forbidigo // Printer purpose is printing to stdout
-			log.Println(product+": ", s)
+			log.Println(product+": ", out)
 		}
 	}
 }
 
-func main() {
-	var (
-		addr = flag.String(
-			"addr",
-			"wss://ws-feed.exchange.coinbase.com",
-			"Coinbase's websocket feed URI",
+// goVWAPPipeline starts the pipeline of a product that outputs the VWAP,
+// or the configured indicators, for every match:
+//
+//	chan coinbase.Match -> [chan coinbase.Match] -> [chan coinbase.Match] -> chan fmt.Stringer -> os.Stdout
+//	matches             -> [reorderer]           -> [filter]              -> vwap              -> printer
+func goVWAPPipeline(
+	ctx context.Context,
+	g *errgroup.Group,
+	cfg config,
+	product string,
+	filter *vwap.OutlierFilter,
+	stage vwapStage,
+	legs chan<- legVWAP,
+) {
+	matches := make(chan coinbase.Match, cfg.bufSize())
+	ordered := goReorderer(ctx, g, cfg, product, matches)
+	accepted := goOutlierFilter(ctx, g, cfg, product, filter, ordered)
+	printer := make(chan fmt.Stringer, cfg.bufSize())
+
+	g.Go(func() error {
+		return runPrinter(ctx, printer, product)
+	})
+
+	g.Go(func() error {
+		defer close(printer)
+		return runVWAPCalculator(
+			ctx,
+			accepted,
+			printer,
+			legs,
+			stage,
+			cfg.snapshotInterval,
+			cfg.profileInterval,
+			product,
 		)
-		products = flag.String(
-			"products",
-			"BTC-USD,ETH-USD,ETH-BTC",
-			"Comma separated list of coinbase's product IDs",
+	})
+
+	g.Go(func() error {
+		defer close(matches)
+		return runMatchesWatcher(
+			ctx,
+			matches,
+			cfg.addr,
+			product,
+			cfg.bufSize(),
 		)
-		windowWidth = flag.Int(
-			"window",
-			//nolint:gomnd // Default value is an educated guess
-			200,
-			"The width of the window for calculating VWAP values",
+	})
+}
+
+// goCandlePipeline starts the pipeline of a product that outputs candles:
+//
+//	chan coinbase.Match -> [chan coinbase.Match] -> [chan coinbase.Match] -> chan vwap.Candle -> os.Stdout or CSV file
+//	matches             -> [reorderer]           -> [filter]              -> candles          -> sink
+func goCandlePipeline(
+	ctx context.Context,
+	g *errgroup.Group,
+	cfg config,
+	product string,
+	filter *vwap.OutlierFilter,
+	write func(product string, c vwap.Candle) error,
+) {
+	matches := make(chan coinbase.Match, cfg.bufSize())
+	ordered := goReorderer(ctx, g, cfg, product, matches)
+	accepted := goOutlierFilter(ctx, g, cfg, product, filter, ordered)
+	candles := make(chan vwap.Candle, cfg.bufSize())
+
+	g.Go(func() error {
+		return runCandleSink(ctx, candles, product, write)
+	})
+
+	g.Go(func() error {
+		defer close(candles)
+		return runCandleBuilder(ctx, accepted, candles, cfg.candles)
+	})
+
+	g.Go(func() error {
+		defer close(matches)
+		return runMatchesWatcher(
+			ctx,
+			matches,
+			cfg.addr,
+			product,
+			cfg.bufSize(),
 		)
+	})
+}
+
+func main() {
+	cfg, err := parseFlags()
+	if err != nil {
+		//nolint:forbidigo // Printing error to os.Stderr on program exit is intentional
+		log.Fatal(err)
+	}
+
+	var (
+		writeCandle = printCandle
+		cw          *csvCandleWriter
 	)
-	flag.Parse()
+	if cfg.candlesCSV != "" {
+		if cw, err = newCSVCandleWriter(cfg.candlesCSV); err != nil {
+			//nolint:forbidigo // Printing error to os.Stderr on program exit is intentional
+			log.Fatal(err)
+		}
+		writeCandle = cw.write
+	}
+
+	if cfg.stateDir != "" {
+		//nolint:gomnd // rwxr-xr-x
+		if err := os.MkdirAll(cfg.stateDir, 0o755); err != nil {
+			//nolint:forbidigo // Printing error to os.Stderr on program exit is intentional
+			log.Fatal(err)
+		}
+	}
 
 	g, ctx := errgroup.WithContext(NewSigKillContext())
 
+	triangles, err := cfg.newTriangles()
+	if err != nil {
+		//nolint:forbidigo // Printing error to os.Stderr on program exit is intentional
+		log.Fatal(err)
+	}
+	var legs chan legVWAP
+	if len(triangles) > 0 {
+		legs = make(chan legVWAP, cfg.bufSize())
+		g.Go(func() error {
+			return runTriangleMonitor(ctx, legs, triangles)
+		})
+	}
+
 	// As per coinbase's documentation best practices:
 	// Spread subscriptions over more than one websocket client connection.
 	//
 	// See: https://docs.cloud.coinbase.com/exchange/docs/websocket-best-practices
-	for _, p := range strings.Split(*products, ",") {
-		// Pipeline for each product p:
-		// chan []coinbase.Match -> chan string -> os.Stdout
-		// matches               -> vwap        -> printer
-		func(p string) {
-			matches := make(chan coinbase.Match, *windowWidth)
-			printer := make(chan string, *windowWidth)
-
-			g.Go(func() error {
-				return runPrinter(ctx, printer, p)
-			})
-
-			g.Go(func() error {
-				defer close(printer)
-				return runVWAPCalculator(
-					ctx,
-					matches,
-					printer,
-					*windowWidth,
-					p,
-				)
-			})
-
-			g.Go(func() error {
-				defer close(matches)
-				return runMatchesWatcher(
-					ctx,
-					matches,
-					*addr,
-					p,
-					*windowWidth,
-				)
-			})
-		}(p)
+	for _, p := range cfg.products {
+		filter, err := cfg.newOutlierFilter()
+		if err != nil {
+			//nolint:forbidigo // Printing error to os.Stderr on program exit is intentional
+			log.Fatal(err)
+		}
+
+		if cfg.candles != 0 {
+			goCandlePipeline(ctx, g, cfg, p, filter, writeCandle)
+			continue
+		}
+
+		stage, err := newVWAPStage(cfg, p)
+		if err != nil {
+			//nolint:forbidigo // Printing error to os.Stderr on program exit is intentional
+			log.Fatal(err)
+		}
+		var productLegs chan<- legVWAP
+		if isLeg(triangles, p) {
+			productLegs = legs
+		}
+		goVWAPPipeline(ctx, g, cfg, p, filter, stage, productLegs)
 	}
 
 	// TODO: recover from panics or let it fail? Cleanup will not be reached if it panics
 	<-ctx.Done()
-	if err := g.Wait(); err != nil && err != context.Canceled {
+	err = g.Wait()
+	if cw != nil {
+		if cerr := cw.Close(); cerr != nil && err == nil {
+			err = cerr
+		}
+	}
+	if err != nil && err != context.Canceled {
 		//nolint:forbidigo // Printing error to os.Stderr on program exit is intentional
 		log.Fatal(err)
 	}
@@ -0,0 +1,521 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/felipeblassioli/vwap/pkg/vwap"
+)
+
+// Values of the -late-matches flag.
+const (
+	lateReport = "report"
+	lateDrop   = "drop"
+)
+
+// boundedWindowWidth is the window width used with -max-age or -max-volume,
+// unless -window is set, so that the age or volume of the trades rather than
+// their number bounds the window. The window only grows as trades arrive, so it costs nothing until
+// it holds that many trades.
+const boundedWindowWidth = 1_000_000
+
+// config holds the command-line flags.
+type config struct {
+	addr         string
+	products     []string
+	windowWidths []int
+	maxAge       time.Duration
+	maxVolume    string
+	bands        []float64
+	sides        bool
+	percentiles  []float64
+	priceRange   bool
+	indicators   []string
+	candles      time.Duration
+	candlesCSV   string
+
+	triangles []string
+
+	lateness    time.Duration
+	lateMatches string
+
+	outlierPct     float64
+	outlierStdDevs float64
+	outlierMedian  bool
+	outlierReset   int
+
+	profileTick     string
+	profileSession  bool
+	profileInterval time.Duration
+
+	stateDir         string
+	snapshotInterval time.Duration
+	snapshotMaxAge   time.Duration
+}
+
+// parseFlags parses the command-line flags into a config.
+func parseFlags() (config, error) {
+	var (
+		addr = flag.String(
+			"addr",
+			"wss://ws-feed.exchange.coinbase.com",
+			"Coinbase's websocket feed URI",
+		)
+		products = flag.String(
+			"products",
+			"BTC-USD,ETH-USD,ETH-BTC",
+			"Comma separated list of coinbase's product IDs",
+		)
+		windows = flag.String(
+			"window",
+			// Default value is an educated guess
+			"200",
+			"Comma separated list of widths of the windows for calculating VWAP values",
+		)
+		maxAge = flag.Duration(
+			"max-age",
+			0,
+			"If set, trades older than max-age are discarded from the window (example: 5m). "+
+				"The window still holds at most -window trades, "+strconv.Itoa(boundedWindowWidth)+" unless -window is set",
+		)
+		maxVolume = flag.String(
+			"max-volume",
+			"",
+			"If set, the window holds the most recent trades summing to max-volume units of the base currency (example: 50). "+
+				"The window still holds at most -window trades, "+strconv.Itoa(boundedWindowWidth)+" unless -window is set",
+		)
+		bands = flag.String(
+			"bands",
+			"",
+			"Comma separated list of standard deviation multiples for VWAP bands (example: 1,2)",
+		)
+		sides = flag.Bool(
+			"sides",
+			false,
+			"Also output buy-side and sell-side VWAPs and the order-flow imbalance",
+		)
+		percentiles = flag.String(
+			"percentiles",
+			"",
+			"Comma separated list of volume-weighted price percentiles to output next to the VWAP (example: 5,50,95)",
+		)
+		priceRange = flag.Bool(
+			"range",
+			false,
+			"Also output the lowest and highest prices of the window",
+		)
+		indicators = flag.String(
+			"indicators",
+			"",
+			"Comma separated list of indicators to output instead of the VWAP: "+
+				"vwap, twap, ewvwap, volume, count, avgsize (example: vwap,twap)",
+		)
+		candles = flag.Duration(
+			"candles",
+			0,
+			"If set, output OHLCV candles of this interval instead of the VWAP (example: 1m)",
+		)
+		candlesCSV = flag.String(
+			"candles-csv",
+			"",
+			"If set, candles are appended to this CSV file instead of printed",
+		)
+		triangles = flag.String(
+			"triangles",
+			"",
+			"Comma separated list of CROSS:VIA triangles whose implied cross rate VWAP is compared to the direct one, "+
+				"all legs must be in -products (example: ETH-BTC:USD)",
+		)
+		lateness = flag.Duration(
+			"lateness",
+			0,
+			"If set, matches are reordered by time, waiting up to this long for late ones (example: 500ms)",
+		)
+		lateMatches = flag.String(
+			"late-matches",
+			lateReport,
+			"How matches too late to be reordered are dropped: report (logged) or drop (silently)",
+		)
+		outlierPct = flag.Float64(
+			"outlier-pct",
+			0,
+			"If set, trades whose price deviates from the VWAP by more than this percentage are rejected (example: 5)",
+		)
+		outlierStdDevs = flag.Float64(
+			"outlier-stddevs",
+			0,
+			"If set, trades whose price deviates from the VWAP by more than this many standard deviations are rejected (example: 6)",
+		)
+		outlierMedian = flag.Bool(
+			"outlier-median",
+			false,
+			"Compare trades against the volume-weighted median price instead of the VWAP for rejecting outliers",
+		)
+		outlierReset = flag.Int(
+			"outlier-reset",
+			0,
+			"If set, the outlier filter follows the market after this many consecutive rejections",
+		)
+		profileTick = flag.String(
+			"profile",
+			"",
+			"If set, periodically output the volume profile of the window with price buckets of this tick size (example: 10)",
+		)
+		profileSession = flag.Bool(
+			"profile-session",
+			false,
+			"Profile every trade since startup instead of the window",
+		)
+		profileInterval = flag.Duration(
+			"profile-interval",
+			time.Minute,
+			"How often the volume profile is output",
+		)
+		stateDir = flag.String(
+			"state-dir",
+			"",
+			"If set, the VWAP window of every product is periodically saved to this directory and restored on startup",
+		)
+		snapshotInterval = flag.Duration(
+			"snapshot-interval",
+			time.Minute,
+			"How often the VWAP windows are saved to -state-dir",
+		)
+		snapshotMaxAge = flag.Duration(
+			"snapshot-max-age",
+			//nolint:gomnd // Default value is an educated guess
+			5*time.Minute,
+			"Saved VWAP windows whose newest trade is older than this are not restored",
+		)
+	)
+	flag.Parse()
+
+	cfg := config{
+		addr:       *addr,
+		products:   strings.Split(*products, ","),
+		maxAge:     *maxAge,
+		maxVolume:  *maxVolume,
+		sides:      *sides,
+		priceRange: *priceRange,
+		candles:    *candles,
+		candlesCSV: *candlesCSV,
+
+		lateness:    *lateness,
+		lateMatches: *lateMatches,
+
+		outlierPct:     *outlierPct,
+		outlierStdDevs: *outlierStdDevs,
+		outlierMedian:  *outlierMedian,
+		outlierReset:   *outlierReset,
+
+		profileTick:     *profileTick,
+		profileSession:  *profileSession,
+		profileInterval: *profileInterval,
+
+		stateDir:         *stateDir,
+		snapshotInterval: *snapshotInterval,
+		snapshotMaxAge:   *snapshotMaxAge,
+	}
+
+	var err error
+	if cfg.windowWidths, err = parseWindowWidths(*windows); err != nil {
+		return cfg, err
+	}
+	if (cfg.maxAge != 0 || cfg.maxVolume != "") && !isFlagSet("window") {
+		cfg.windowWidths = []int{boundedWindowWidth}
+	}
+
+	if *bands != "" {
+		for _, s := range strings.Split(*bands, ",") {
+			k, err := strconv.ParseFloat(strings.TrimSpace(s), 64)
+			if err != nil {
+				return cfg, fmt.Errorf("invalid band multiplier: %w", err)
+			}
+			cfg.bands = append(cfg.bands, k)
+		}
+	}
+
+	if *percentiles != "" {
+		for _, s := range strings.Split(*percentiles, ",") {
+			p, err := strconv.ParseFloat(strings.TrimSpace(s), 64)
+			if err != nil {
+				return cfg, fmt.Errorf("invalid percentile: %w", err)
+			}
+			cfg.percentiles = append(cfg.percentiles, p)
+		}
+	}
+
+	if *triangles != "" {
+		for _, t := range strings.Split(*triangles, ",") {
+			cfg.triangles = append(cfg.triangles, strings.TrimSpace(t))
+		}
+	}
+
+	if *indicators != "" {
+		for _, name := range strings.Split(*indicators, ",") {
+			cfg.indicators = append(cfg.indicators, strings.TrimSpace(name))
+		}
+	}
+
+	return cfg, cfg.validate()
+}
+
+// validate reports combinations of flags that are not supported.
+func (cfg config) validate() error {
+	if len(cfg.windowWidths) > 1 &&
+		(cfg.maxAge != 0 || cfg.maxVolume != "" || len(cfg.bands) > 0 || cfg.sides || len(cfg.percentiles) > 0 || cfg.priceRange ||
+			len(cfg.indicators) > 0) {
+		return errors.New("-max-age, -max-volume, -bands, -sides, -percentiles, -range and -indicators " +
+			"are not supported with multiple windows")
+	}
+	if len(cfg.indicators) > 0 && (len(cfg.bands) > 0 || cfg.sides || len(cfg.percentiles) > 0 || cfg.priceRange) {
+		return errors.New("-bands, -sides, -percentiles and -range are not supported with -indicators")
+	}
+	if (cfg.maxAge != 0 || cfg.maxVolume != "") && cfg.hasIndicator("twap", "ewvwap") {
+		return errors.New("-max-age and -max-volume are not supported with the twap and ewvwap indicators")
+	}
+	if cfg.candles != 0 && (len(cfg.indicators) > 0 || len(cfg.bands) > 0 || cfg.sides || len(cfg.percentiles) > 0 || cfg.priceRange) {
+		return errors.New("-indicators, -bands, -sides, -percentiles and -range are not supported with -candles")
+	}
+	if cfg.candlesCSV != "" && cfg.candles == 0 {
+		return errors.New("-candles-csv requires -candles")
+	}
+	if cfg.stateDir != "" && (len(cfg.windowWidths) > 1 || len(cfg.indicators) > 0 || cfg.candles != 0) {
+		return errors.New("-state-dir is not supported with multiple windows, -indicators or -candles")
+	}
+	if cfg.lateness < 0 {
+		return errors.New("-lateness must not be negative")
+	}
+	if cfg.lateMatches != lateReport && cfg.lateMatches != lateDrop {
+		return fmt.Errorf("invalid -late-matches %q: want %s or %s", cfg.lateMatches, lateReport, lateDrop)
+	}
+	if len(cfg.triangles) > 0 && (len(cfg.indicators) > 0 || cfg.candles != 0) {
+		return errors.New("-triangles is not supported with -indicators or -candles")
+	}
+	if cfg.outlierPct == 0 && cfg.outlierStdDevs == 0 && (cfg.outlierMedian || cfg.outlierReset != 0) {
+		return errors.New("-outlier-median and -outlier-reset require -outlier-pct or -outlier-stddevs")
+	}
+	if cfg.profileTick != "" && cfg.candles != 0 {
+		return errors.New("-profile is not supported with -candles")
+	}
+	if cfg.profileTick != "" && !cfg.profileSession && (len(cfg.windowWidths) > 1 || len(cfg.indicators) > 0) {
+		return errors.New("-profile requires -profile-session with multiple windows or -indicators")
+	}
+	if cfg.profileSession && cfg.profileTick == "" {
+		return errors.New("-profile-session requires -profile")
+	}
+	if cfg.profileTick != "" && cfg.profileInterval <= 0 {
+		return errors.New("-profile-interval must be positive")
+	}
+	if cfg.stateDir != "" && cfg.snapshotInterval <= 0 {
+		return errors.New("-snapshot-interval must be positive")
+	}
+
+	return nil
+}
+
+// bufSize returns the size of the go channels, which hold a full window of
+// the widest window.
+func (cfg config) bufSize() int {
+	size := 0
+	for _, w := range cfg.windowWidths {
+		if w > size {
+			size = w
+		}
+	}
+
+	return size
+}
+
+// isFlagSet reports whether the named flag was set on the command line.
+func isFlagSet(name string) bool {
+	set := false
+	flag.Visit(func(f *flag.Flag) {
+		if f.Name == name {
+			set = true
+		}
+	})
+
+	return set
+}
+
+// parseWindowWidths parses a comma separated list of window widths.
+func parseWindowWidths(s string) ([]int, error) {
+	var widths []int
+	for _, w := range strings.Split(s, ",") {
+		width, err := strconv.Atoi(strings.TrimSpace(w))
+		if err != nil {
+			return nil, fmt.Errorf("invalid window width: %w", err)
+		}
+		widths = append(widths, width)
+	}
+
+	return widths, nil
+}
+
+// windowOptions translates the command-line flags into the vwap.Calculator
+// options that shape its window.
+func (cfg config) windowOptions() []vwap.Option {
+	var opts []vwap.Option
+	if cfg.maxAge != 0 {
+		opts = append(opts, vwap.WithMaxAge(cfg.maxAge))
+	}
+
+	if cfg.maxVolume != "" {
+		opts = append(opts, vwap.WithVolumeWindow(cfg.maxVolume))
+	}
+
+	return opts
+}
+
+// calculatorOptions translates the command-line flags into vwap.Calculator
+// options.
+func (cfg config) calculatorOptions() []vwap.Option {
+	opts := cfg.windowOptions()
+	if len(cfg.bands) > 0 {
+		opts = append(opts, vwap.WithBands(cfg.bands...))
+	}
+
+	if cfg.sides {
+		opts = append(opts, vwap.WithSideSplit())
+	}
+
+	if len(cfg.percentiles) > 0 {
+		opts = append(opts, vwap.WithPercentiles(cfg.percentiles...))
+	}
+
+	if cfg.priceRange {
+		opts = append(opts, vwap.WithRange())
+	}
+
+	if cfg.profileTick != "" && !cfg.profileSession {
+		opts = append(opts, vwap.WithVolumeProfile(cfg.profileTick))
+	}
+
+	return opts
+}
+
+// hasIndicator reports whether any of the named indicators is configured.
+func (cfg config) hasIndicator(names ...string) bool {
+	for _, i := range cfg.indicators {
+		for _, name := range names {
+			if i == name {
+				return true
+			}
+		}
+	}
+
+	return false
+}
+
+// newIndicators creates the indicators named in the command-line flags.
+// The window indicators share a single vwap.Calculator.
+func (cfg config) newIndicators() (vwap.Indicators, error) {
+	var (
+		is vwap.Indicators
+		ww = cfg.windowWidths[0]
+		// calc is created with the first window indicator.
+		calc *vwap.Calculator
+	)
+	for _, name := range cfg.indicators {
+		var (
+			i   vwap.Indicator
+			err error
+		)
+		switch name {
+		case "vwap", "volume", "count", "avgsize":
+			if calc == nil {
+				calc, err = vwap.NewCalculator(ww, cfg.windowOptions()...)
+			}
+			if err == nil {
+				i = windowIndicator(calc, name)
+			}
+		case "twap":
+			i, err = vwap.NewTWAP(ww)
+		case "ewvwap":
+			// The smoothing factor that roughly matches the window width
+			//nolint:gomnd // See vwap.NewEWVWAP
+			i, err = vwap.NewEWVWAP(2 / float64(ww+1))
+		default:
+			err = fmt.Errorf("unknown indicator: %q", name)
+		}
+		if err != nil {
+			return nil, err
+		}
+		is = append(is, i)
+	}
+
+	return is, nil
+}
+
+// windowIndicator returns the window indicator of calc named name, one of
+// vwap, volume, count and avgsize.
+func windowIndicator(calc *vwap.Calculator, name string) vwap.Indicator {
+	switch name {
+	case "vwap":
+		return calc.VWAPIndicator()
+	case "volume":
+		return calc.VolumeIndicator()
+	case "count":
+		return calc.TradeCountIndicator()
+	default:
+		return calc.AverageSizeIndicator()
+	}
+}
+
+// newOutlierFilter creates the outlier filter configured in the
+// command-line flags, or returns nil if it is disabled.
+func (cfg config) newOutlierFilter() (*vwap.OutlierFilter, error) {
+	if cfg.outlierPct == 0 && cfg.outlierStdDevs == 0 {
+		return nil, nil
+	}
+
+	var opts []vwap.FilterOption
+	if cfg.outlierPct != 0 {
+		opts = append(opts, vwap.WithMaxDeviation(cfg.outlierPct))
+	}
+	if cfg.outlierStdDevs != 0 {
+		opts = append(opts, vwap.WithMaxStdDevs(cfg.outlierStdDevs))
+	}
+	if cfg.outlierMedian {
+		opts = append(opts, vwap.WithMedianReference())
+	}
+	if cfg.outlierReset != 0 {
+		opts = append(opts, vwap.WithResetAfter(cfg.outlierReset))
+	}
+
+	return vwap.NewOutlierFilter(cfg.bufSize(), opts...)
+}
+
+// newTriangles creates the triangles of the command-line flags, checking
+// that their legs are among the products.
+func (cfg config) newTriangles() ([]*vwap.Triangle, error) {
+	products := make(map[string]bool, len(cfg.products))
+	for _, p := range cfg.products {
+		products[p] = true
+	}
+
+	var triangles []*vwap.Triangle
+	for _, spec := range cfg.triangles {
+		cross, via, ok := strings.Cut(spec, ":")
+		if !ok {
+			return nil, fmt.Errorf("invalid triangle %q: want CROSS:VIA", spec)
+		}
+		t, err := vwap.NewTriangle(cross, via)
+		if err != nil {
+			return nil, err
+		}
+		for _, leg := range t.Legs() {
+			if !products[leg] {
+				return nil, fmt.Errorf("triangle %s: leg %s is not in -products", t, leg)
+			}
+		}
+		triangles = append(triangles, t)
+	}
+
+	return triangles, nil
+}
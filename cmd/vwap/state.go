@@ -0,0 +1,93 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"log"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/felipeblassioli/vwap/pkg/vwap"
+)
+
+// snapshotPath returns the path of the snapshot file of a product.
+func snapshotPath(stateDir, product string) string {
+	return filepath.Join(stateDir, product+".snapshot")
+}
+
+// saveSnapshot writes a snapshot of calc to path. The snapshot is written to
+// a temporary file first and then renamed, so that a crash never leaves a
+// partially written snapshot behind.
+func saveSnapshot(path string, calc *vwap.Calculator) error {
+	b, err := calc.MarshalBinary()
+	if err != nil {
+		return fmt.Errorf("snapshot: %w", err)
+	}
+
+	f, err := os.CreateTemp(filepath.Dir(path), filepath.Base(path)+".*.tmp")
+	if err != nil {
+		return fmt.Errorf("snapshot: %w", err)
+	}
+	defer os.Remove(f.Name()) //nolint:errcheck // Fails once renamed
+	if _, err := f.Write(b); err != nil {
+		f.Close()
+		return fmt.Errorf("snapshot: %w", err)
+	}
+	// The data must reach the disk before the rename does, or a power loss
+	// may leave the renamed file empty.
+	if err := f.Sync(); err != nil {
+		f.Close()
+		return fmt.Errorf("snapshot: %w", err)
+	}
+	if err := f.Close(); err != nil {
+		return fmt.Errorf("snapshot: %w", err)
+	}
+
+	if err := os.Rename(f.Name(), path); err != nil {
+		return fmt.Errorf("snapshot: %w", err)
+	}
+
+	return nil
+}
+
+// restoreSnapshot creates a vwap.Calculator with the given window width and
+// options, restored from the snapshot at path. It returns nil if there is no
+// snapshot or its newest trade is older than maxAge.
+//
+// Unusable snapshots (e.g. taken with other options) are not an error
+// either: the caller simply starts with an empty window.
+func restoreSnapshot(
+	path string,
+	maxAge time.Duration,
+	windowWidth int,
+	opts []vwap.Option,
+) (*vwap.Calculator, error) {
+	b, err := os.ReadFile(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("snapshot: %w", err)
+	}
+
+	calc, err := vwap.NewCalculator(windowWidth, opts...)
+	if err != nil {
+		return nil, err
+	}
+	if err := calc.UnmarshalBinary(b); err != nil {
+		//nolint:forbidigo // Warning that the state is discarded
+		log.Println("Ignoring snapshot", path+":", err)
+		return nil, nil
+	}
+	if age := time.Since(calc.Value().Newest); age > maxAge {
+		if debug {
+			//nolint:forbidigo // Removed by the compiler
+			log.Println("Ignoring stale snapshot", path, age)
+		}
+		return nil, nil
+	}
+
+	return calc, nil
+}
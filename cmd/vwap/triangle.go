@@ -0,0 +1,47 @@
+package main
+
+import (
+	"context"
+	"log"
+	"math/big"
+
+	"github.com/felipeblassioli/vwap/pkg/vwap"
+)
+
+// legVWAP is the VWAP of a product that may be the leg of a triangle.
+type legVWAP struct {
+	product string
+	vwap    *big.Float
+}
+
+// runTriangleMonitor receives the VWAPs of the legs of the triangles from
+// the VWAP pipelines and outputs the spread between the direct and the
+// implied cross rate of every triangle whose leg was updated.
+func runTriangleMonitor(ctx context.Context, legs <-chan legVWAP, triangles []*vwap.Triangle) error {
+	for {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case l := <-legs:
+			for _, t := range triangles {
+				if s, ok := t.Update(l.product, l.vwap); ok {
+					//nolint:forbidigo // The monitor's purpose is printing to stdout
+					log.Println(t.String()+": ", s)
+				}
+			}
+		}
+	}
+}
+
+// isLeg reports whether product is a leg of any of the triangles.
+func isLeg(triangles []*vwap.Triangle, product string) bool {
+	for _, t := range triangles {
+		for _, leg := range t.Legs() {
+			if leg == product {
+				return true
+			}
+		}
+	}
+
+	return false
+}
@@ -0,0 +1,151 @@
+package coinbase
+
+import (
+	"container/heap"
+	"errors"
+	"fmt"
+	"time"
+)
+
+// ErrLateMatch is returned by Reorderer.Push for matches that arrive after
+// matches that follow them were released, or that were already received.
+var ErrLateMatch = errors.New("match arrived after the watermark")
+
+// Reorderer restores the order of matches that arrive out of order, e.g.
+// after reconnects or when merging sources.
+//
+// Matches are ordered by Time, then by TradeID. They are held until the
+// watermark, the time of the newest match received minus the lateness
+// tolerance, passes them. A Reorderer is not safe for concurrent use.
+type Reorderer struct {
+	lateness time.Duration
+
+	// pending holds the matches not released yet, and ids their trade IDs.
+	pending matchHeap
+	ids     map[int]struct{}
+
+	// newest is the time of the newest match received, and newestAt the
+	// local time it was received at, by clock.
+	newest   time.Time
+	newestAt time.Time
+	clock    func() time.Time
+
+	// last is the last match released.
+	last     Match
+	released bool
+
+	late int
+}
+
+// NewReorderer returns a Reorderer that holds matches for up to lateness
+// after newer matches are received.
+func NewReorderer(lateness time.Duration) *Reorderer {
+	return &Reorderer{
+		lateness: lateness,
+		ids:      make(map[int]struct{}),
+		clock:    time.Now,
+	}
+}
+
+// Push adds a match and returns the matches the watermark passed, in order.
+//
+// Matches ordered before the last released one, or received twice, cannot
+// be released in order anymore: they are dropped and ErrLateMatch is
+// returned. See Late.
+func (r *Reorderer) Push(m Match) ([]Match, error) {
+	_, pending := r.ids[m.TradeID]
+	if pending || (r.released && !before(r.last, m)) {
+		r.late++
+		return nil, fmt.Errorf("%w: trade %d at %s", ErrLateMatch, m.TradeID, m.Time.Format(time.RFC3339Nano))
+	}
+
+	heap.Push(&r.pending, m)
+	r.ids[m.TradeID] = struct{}{}
+	if m.Time.After(r.newest) {
+		r.newest = m.Time
+		r.newestAt = r.clock()
+	}
+
+	return r.release(r.newest.Add(-r.lateness)), nil
+}
+
+// Flush returns the matches the watermark passed, in order, as if the
+// watermark advanced with the local clock since the newest match was
+// received.
+//
+// It releases matches while no newer ones are received, e.g. on a quiet
+// feed. Only the time elapsed locally is used, so the clocks of both ends
+// need not agree.
+func (r *Reorderer) Flush() []Match {
+	elapsed := r.clock().Sub(r.newestAt)
+
+	return r.release(r.newest.Add(elapsed - r.lateness))
+}
+
+// Drain returns all the matches not released yet, in order.
+func (r *Reorderer) Drain() []Match {
+	var ms []Match
+	for r.pending.Len() > 0 {
+		ms = append(ms, r.pop())
+	}
+
+	return ms
+}
+
+// Len returns the number of matches not released yet.
+func (r *Reorderer) Len() int {
+	return r.pending.Len()
+}
+
+// Late returns the number of matches dropped for arriving late.
+func (r *Reorderer) Late() int {
+	return r.late
+}
+
+// release returns the pending matches up to watermark, in order.
+func (r *Reorderer) release(watermark time.Time) []Match {
+	var ms []Match
+	for r.pending.Len() > 0 && !r.pending[0].Time.After(watermark) {
+		ms = append(ms, r.pop())
+	}
+
+	return ms
+}
+
+// pop releases the first pending match.
+func (r *Reorderer) pop() Match {
+	m := heap.Pop(&r.pending).(Match)
+	delete(r.ids, m.TradeID)
+	r.last = m
+	r.released = true
+
+	return m
+}
+
+// before reports whether match a is ordered before match b.
+func before(a, b Match) bool {
+	if !a.Time.Equal(b.Time) {
+		return a.Time.Before(b.Time)
+	}
+
+	return a.TradeID < b.TradeID
+}
+
+// matchHeap is a min-heap of matches implementing heap.Interface.
+type matchHeap []Match
+
+func (h matchHeap) Len() int           { return len(h) }
+func (h matchHeap) Less(i, j int) bool { return before(h[i], h[j]) }
+func (h matchHeap) Swap(i, j int)      { h[i], h[j] = h[j], h[i] }
+
+func (h *matchHeap) Push(x any) {
+	*h = append(*h, x.(Match))
+}
+
+func (h *matchHeap) Pop() any {
+	old := *h
+	m := old[len(old)-1]
+	*h = old[:len(old)-1]
+
+	return m
+}
@@ -0,0 +1,99 @@
+package coinbase
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestReorderer(t *testing.T) {
+	t0 := time.Date(2022, 9, 12, 14, 28, 52, 0, time.UTC)
+	match := func(id int, offset time.Duration) Match {
+		return Match{TradeID: id, Time: t0.Add(offset)}
+	}
+	ids := func(ms []Match) []int {
+		var ids []int
+		for _, m := range ms {
+			ids = append(ids, m.TradeID)
+		}
+		return ids
+	}
+
+	t.Run("No lateness releases immediately", func(t *testing.T) {
+		r := NewReorderer(0)
+
+		ms, err := r.Push(match(1, 0))
+		assert.Nil(t, err)
+		assert.Equal(t, []int{1}, ids(ms))
+		assert.Equal(t, 0, r.Len())
+	})
+
+	t.Run("Releases in order once the watermark passes", func(t *testing.T) {
+		r := NewReorderer(time.Second)
+
+		ms, _ := r.Push(match(2, 100*time.Millisecond))
+		assert.Empty(t, ms)
+		ms, _ = r.Push(match(1, 0))
+		assert.Empty(t, ms)
+		// Same time, ordered by trade ID
+		ms, _ = r.Push(match(4, 500*time.Millisecond))
+		assert.Empty(t, ms)
+		ms, _ = r.Push(match(3, 500*time.Millisecond))
+		assert.Empty(t, ms)
+		assert.Equal(t, 4, r.Len())
+
+		ms, _ = r.Push(match(5, 1100*time.Millisecond))
+		assert.Equal(t, []int{1, 2}, ids(ms))
+
+		ms, _ = r.Push(match(6, 2*time.Second))
+		assert.Equal(t, []int{3, 4}, ids(ms))
+
+		assert.Equal(t, []int{5, 6}, ids(r.Drain()))
+		assert.Equal(t, 0, r.Len())
+	})
+
+	t.Run("Late matches", func(t *testing.T) {
+		r := NewReorderer(time.Second)
+		r.Push(match(2, 0))
+		r.Push(match(3, 2*time.Second))
+
+		// Before the last released match
+		ms, err := r.Push(match(1, -time.Second))
+		assert.Empty(t, ms)
+		assert.ErrorIs(t, err, ErrLateMatch)
+
+		// Already released
+		_, err = r.Push(match(2, 0))
+		assert.ErrorIs(t, err, ErrLateMatch)
+
+		// Still pending
+		_, err = r.Push(match(3, 2*time.Second))
+		assert.ErrorIs(t, err, ErrLateMatch)
+
+		// Past the watermark, but after the last released match
+		ms, err = r.Push(match(4, 500*time.Millisecond))
+		assert.Nil(t, err)
+		assert.Equal(t, []int{4}, ids(ms))
+
+		assert.Equal(t, 3, r.Late())
+		assert.Equal(t, []int{3}, ids(r.Drain()))
+	})
+
+	t.Run("Flush by the clock", func(t *testing.T) {
+		r := NewReorderer(time.Second)
+		// The local clock is an hour ahead of the exchange's
+		now := t0.Add(time.Hour)
+		r.clock = func() time.Time { return now }
+		r.Push(match(1, 0))
+		r.Push(match(2, 500*time.Millisecond))
+
+		now = now.Add(400 * time.Millisecond)
+		assert.Empty(t, r.Flush())
+		now = now.Add(100 * time.Millisecond)
+		assert.Equal(t, []int{1}, ids(r.Flush()))
+		now = now.Add(time.Hour)
+		assert.Equal(t, []int{2}, ids(r.Flush()))
+		assert.Empty(t, r.Drain())
+	})
+}
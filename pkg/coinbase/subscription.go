@@ -41,6 +41,21 @@ type Match struct {
 	Side string `json:"side"`
 }
 
+// TakerSide returns the side of the aggressor (taker) order, which is the
+// opposite of Side: "buy" for an up-tick and "sell" for a down-tick.
+//
+// It returns an empty string if Side is neither "buy" nor "sell".
+func (m Match) TakerSide() string {
+	switch m.Side {
+	case "buy":
+		return "sell"
+	case "sell":
+		return "buy"
+	default:
+		return ""
+	}
+}
+
 // Subscription watches Coinbase Websocket Feed Match updates.
 // All updates are sent to the go channel C.
 type Subscription struct {
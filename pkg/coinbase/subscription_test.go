@@ -0,0 +1,23 @@
+package coinbase
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMatch_TakerSide(t *testing.T) {
+	tests := []struct {
+		side string
+		exp  string
+	}{
+		{"sell", "buy"},
+		{"buy", "sell"},
+		{"", ""},
+		{"other", ""},
+	}
+
+	for _, tc := range tests {
+		assert.Equal(t, tc.exp, Match{Side: tc.side}.TakerSide(), tc.side)
+	}
+}
@@ -0,0 +1,106 @@
+package ringbuf
+
+// PushSlice appends the elements of items to the back of the queue, in order,
+// and returns how many elements were discarded to make room for them.
+//
+// Like PushBack, if the ring buffer is full, the elements at the front of the
+// queue are discarded, and then the first elements of items if there are
+// more of them than the ring buffer can hold. A growable ring buffer grows
+// to fit them instead, up to its maximum capacity.
+func (r *RingBuffer[T]) PushSlice(items []T) (evicted int) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.reserve(len(items))
+
+	size := len(r.buf)
+	if size == 0 {
+		// A zero capacity buffer discards every element.
+		return len(items)
+	}
+	if excess := r.count + len(items) - size; excess > 0 {
+		evicted = excess
+		if excess > r.count {
+			items = items[excess-r.count:]
+			excess = r.count
+		}
+		r.start = (r.start + excess) % size
+		r.count -= excess
+	}
+	if len(items) == 0 {
+		return evicted
+	}
+
+	// The elements wrap around the end of buf at most once.
+	n := copy(r.buf[r.end:], items)
+	copy(r.buf, items[n:])
+	r.end = (r.end + len(items)) % size
+	r.count += len(items)
+
+	return evicted
+}
+
+// PopN removes up to len(dst) elements from the front of the queue into dst,
+// and returns how many it removed. Unlike PopFront, it does not panic if the
+// ring buffer holds fewer elements, or none.
+func (r *RingBuffer[T]) PopN(dst []T) int {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	n := r.read(dst)
+	r.start = (r.start + n) % max(len(r.buf), 1)
+	r.count -= n
+	r.maybeShrink()
+
+	return n
+}
+
+// Drain removes and returns all the elements of the queue, from front to
+// back.
+func (r *RingBuffer[T]) Drain() []T {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	items := make([]T, r.count)
+	r.read(items)
+	r.start, r.end, r.count = 0, 0, 0
+	r.maybeShrink()
+
+	return items
+}
+
+// read copies up to len(dst) elements from the front of the queue into dst,
+// without removing them, and returns how many it copied. It must be called
+// with r.mu held.
+func (r *RingBuffer[T]) read(dst []T) int {
+	count := min(len(dst), r.count)
+	// The elements wrap around the end of buf at most once.
+	n := copy(dst[:count], r.buf[r.start:min(r.start+count, len(r.buf))])
+	copy(dst[n:count], r.buf[:count-n])
+
+	return count
+}
+
+// reserve grows a growable ring buffer to fit n more elements, up to its
+// maximum capacity. It must be called with r.mu held.
+func (r *RingBuffer[T]) reserve(n int) {
+	if !r.growable || r.count+n <= len(r.buf) {
+		return
+	}
+
+	size := max(len(r.buf), 1)
+	for size < r.count+n {
+		size *= 2
+	}
+	if r.maxCap > 0 && size > r.maxCap {
+		size = r.maxCap
+	}
+	if size > len(r.buf) {
+		r.resize(size)
+	}
+}
+
+func max(a, b int) int {
+	if a > b {
+		return a
+	}
+
+	return b
+}
@@ -0,0 +1,188 @@
+package ringbuf
+
+import (
+	"testing"
+	"testing/quick"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRingBuffer_PushSlice(t *testing.T) {
+	tests := []struct {
+		name    string
+		maxCap  int
+		pushed  []int
+		items   []int
+		exp     []int
+		evicted int
+	}{
+		{"Empty slice", 3, []int{1}, nil, []int{1}, 0},
+		{"Fits", 4, []int{1}, []int{2, 3}, []int{1, 2, 3}, 0},
+		{"Wraps around", 3, []int{1, 2, 3, 4}, []int{5, 6}, []int{4, 5, 6}, 2},
+		{"Evicts the front", 3, []int{1, 2}, []int{3, 4}, []int{2, 3, 4}, 1},
+		{"Longer than the capacity", 3, []int{1, 2}, []int{3, 4, 5, 6, 7}, []int{5, 6, 7}, 4},
+		{"Zero capacity", 0, nil, []int{1, 2}, []int{}, 2},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			rb := NewRingBuffer[int](tc.maxCap)
+			for _, item := range tc.pushed {
+				rb.PushBack(item)
+			}
+
+			assert.Equal(t, tc.evicted, rb.PushSlice(tc.items))
+			assert.Equal(t, tc.exp, rb.Snapshot())
+		})
+	}
+
+	t.Run("Growable", func(t *testing.T) {
+		rb := NewGrowableRingBuffer[int](2)
+		rb.PushBack(1)
+
+		assert.Equal(t, 0, rb.PushSlice([]int{2, 3, 4, 5, 6}))
+		assert.Equal(t, 8, rb.Cap())
+		assert.Equal(t, []int{1, 2, 3, 4, 5, 6}, rb.Snapshot())
+	})
+
+	t.Run("Growable up to the maximum capacity", func(t *testing.T) {
+		rb := NewGrowableRingBuffer[int](2, WithMaxCap(5))
+		rb.PushBack(1)
+
+		assert.Equal(t, 1, rb.PushSlice([]int{2, 3, 4, 5, 6}))
+		assert.Equal(t, 5, rb.Cap())
+		assert.Equal(t, []int{2, 3, 4, 5, 6}, rb.Snapshot())
+	})
+}
+
+func TestRingBuffer_PopN(t *testing.T) {
+	rb := NewRingBuffer[int](4)
+	rb.PushSlice([]int{1, 2, 3, 4, 5, 6})
+
+	dst := make([]int, 3)
+	assert.Equal(t, 3, rb.PopN(dst))
+	assert.Equal(t, []int{3, 4, 5}, dst)
+
+	// Fewer elements than room in dst
+	assert.Equal(t, 1, rb.PopN(dst))
+	assert.Equal(t, 6, dst[0])
+	assert.Equal(t, 0, rb.PopN(dst))
+	assert.Equal(t, 0, rb.Len())
+
+	t.Run("Zero capacity", func(t *testing.T) {
+		rb := NewRingBuffer[int](0)
+		assert.Equal(t, 0, rb.PopN(dst))
+	})
+}
+
+func TestRingBuffer_Drain(t *testing.T) {
+	rb := NewRingBuffer[int](3)
+	rb.PushSlice([]int{1, 2, 3, 4})
+
+	assert.Equal(t, []int{2, 3, 4}, rb.Drain())
+	assert.Equal(t, 0, rb.Len())
+	assert.Equal(t, []int{}, rb.Drain())
+
+	// Still usable
+	rb.PushBack(5)
+	assert.Equal(t, []int{5}, rb.Snapshot())
+}
+
+// TestRingBuffer_BulkModel checks random sequences of bulk operations
+// against a slice-based reference model of a FIFO queue.
+func TestRingBuffer_BulkModel(t *testing.T) {
+	check := func(maxCap uint8, ops []uint8) bool {
+		var (
+			size  = int(maxCap % 16)
+			rb    = NewRingBuffer[int](size)
+			model = []int{}
+			next  int
+		)
+		for _, op := range ops {
+			n := int(op >> 2)
+			switch op % 3 {
+			case 0:
+				items := make([]int, n)
+				for i := range items {
+					items[i] = next
+					next++
+				}
+				model = append(model, items...)
+				evicted := 0
+				if len(model) > size {
+					evicted = len(model) - size
+					model = model[evicted:]
+				}
+				if rb.PushSlice(items) != evicted {
+					return false
+				}
+			case 1:
+				dst := make([]int, n)
+				popped := rb.PopN(dst)
+				if popped != min(n, len(model)) {
+					return false
+				}
+				for i := 0; i < popped; i++ {
+					if dst[i] != model[i] {
+						return false
+					}
+				}
+				model = model[popped:]
+			case 2:
+				if !assert.ObjectsAreEqual(model, rb.Snapshot()) {
+					return false
+				}
+			}
+		}
+
+		return assert.ObjectsAreEqual(model, rb.Drain())
+	}
+
+	if err := quick.Check(check, nil); err != nil {
+		t.Error(err)
+	}
+}
+
+// benchmarkBatchSize is the number of elements moved at a time by the bulk
+// operation benchmarks.
+const benchmarkBatchSize = 64
+
+func Benchmark_PushBackBatch(b *testing.B) {
+	var (
+		rb    = NewRingBuffer[int](benchmarkQueueSize)
+		items = make([]int, benchmarkBatchSize)
+	)
+
+	for i := 0; i < b.N; i++ {
+		for _, item := range items {
+			rb.PushBack(item)
+		}
+		for range items {
+			rb.PopFront()
+		}
+	}
+}
+
+func Benchmark_PushSlice(b *testing.B) {
+	var (
+		rb    = NewRingBuffer[int](benchmarkQueueSize)
+		items = make([]int, benchmarkBatchSize)
+	)
+
+	for i := 0; i < b.N; i++ {
+		rb.PushSlice(items)
+		rb.PopN(items)
+	}
+}
+
+func Benchmark_Drain(b *testing.B) {
+	var (
+		rb    = NewRingBuffer[int](benchmarkQueueSize)
+		items = make([]int, benchmarkBatchSize)
+	)
+
+	for i := 0; i < b.N; i++ {
+		rb.PushSlice(items)
+		rb.Drain()
+	}
+}
@@ -8,6 +8,45 @@
 //
 // It is also known as circular buffer, circular queue, cyclic buffer.
 //
+// # Growable ring buffer
+//
+// NewGrowableRingBuffer creates a RingBuffer that doubles its buffer when an
+// element is pushed while it is full, rather than overwriting the oldest one.
+// It can be bounded, see WithMaxCap, and shrink back as it empties, see
+// WithShrink.
+//
+// # Time-indexed ring buffer
+//
+// TimeRingBuffer holds timestamped elements in time order. Elements older
+// than a cutoff are evicted from the front with EvictBefore, and Search finds
+// the first element at or after a time in O(log n).
+//
+// # Monotonic deque
+//
+// MonotonicDeque tracks the minimum or maximum of a sliding window in O(1)
+// amortised time per element, holding only the elements that may still
+// become it.
+//
+// # Persistent ring buffer
+//
+// PersistentRingBuffer is a ring buffer of fixed-size records in a
+// memory-mapped file, whose records survive a crash or restart of the
+// process. Records torn by a crash are discarded when the file is opened
+// again. It is only available on Linux.
+//
+// # Blocking queue
+//
+// Queue is a bounded FIFO queue on a RingBuffer whose Push and Pop block
+// while it is full or empty, until their context is done. Like a channel, it
+// can be closed. Unlike a channel, its depth can be monitored, see
+// WithWatermarks.
+//
+// # Lock-free ring buffer
+//
+// SPSC is a ring buffer without locks for exactly one producer goroutine and
+// one consumer goroutine. Unlike RingBuffer, it does not overwrite elements
+// when full.
+//
 // # Generics
 //
 // ringbuf uses generics to create a RingBuffer that contains items of the type
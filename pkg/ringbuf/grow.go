@@ -0,0 +1,104 @@
+package ringbuf
+
+// GrowOption configures a growable RingBuffer.
+type GrowOption func(*growPolicy)
+
+// growPolicy holds the options of a growable RingBuffer.
+type growPolicy struct {
+	maxCap int
+	shrink bool
+}
+
+// WithMaxCap bounds the capacity of a growable RingBuffer: once it holds
+// maxCap elements, it is full.
+func WithMaxCap(maxCap int) GrowOption {
+	return func(p *growPolicy) {
+		p.maxCap = maxCap
+	}
+}
+
+// WithShrink halves the capacity of a growable RingBuffer, down to its
+// initial capacity, whenever popping an element leaves it at most a quarter
+// full.
+func WithShrink() GrowOption {
+	return func(p *growPolicy) {
+		p.shrink = true
+	}
+}
+
+// NewGrowableRingBuffer creates a new RingBuffer with a given initial
+// capacity that doubles its capacity when an element is pushed while it is
+// full, so pushes take amortised constant time.
+//
+// Unless a maximum capacity is set, it is never full. See WithMaxCap.
+func NewGrowableRingBuffer[T any](initCap int, opts ...GrowOption) *RingBuffer[T] {
+	var p growPolicy
+	for _, opt := range opts {
+		opt(&p)
+	}
+	if p.maxCap > 0 && initCap > p.maxCap {
+		initCap = p.maxCap
+	}
+
+	return &RingBuffer[T]{
+		buf:      make([]T, initCap),
+		maxCap:   p.maxCap,
+		growable: true,
+		shrink:   p.shrink,
+		minCap:   initCap,
+	}
+}
+
+// full reports whether there is no room for another element, after growing
+// the ring buffer if it can. It must be called with r.mu held.
+func (r *RingBuffer[T]) full() bool {
+	if r.count < len(r.buf) {
+		return false
+	}
+	if !r.growable || (r.maxCap > 0 && len(r.buf) >= r.maxCap) {
+		return true
+	}
+
+	size := 2 * len(r.buf)
+	if size == 0 {
+		size = 1
+	}
+	if r.maxCap > 0 && size > r.maxCap {
+		size = r.maxCap
+	}
+	r.resize(size)
+
+	return false
+}
+
+// maybeShrink halves the capacity of a shrinkable ring buffer that is at
+// most a quarter full. It must be called with r.mu held.
+//
+// Shrinking to half, rather than to a quarter, leaves room for as many
+// pushes as pops took to get here before growing again.
+func (r *RingBuffer[T]) maybeShrink() {
+	//nolint:gomnd // A quarter
+	if !r.shrink || len(r.buf) <= r.minCap || r.count > len(r.buf)/4 {
+		return
+	}
+
+	size := len(r.buf) / 2
+	if size < r.minCap {
+		size = r.minCap
+	}
+	if size == 0 {
+		size = 1
+	}
+	r.resize(size)
+}
+
+// resize moves the elements to the front of a new buf of size elements. It
+// must be called with r.mu held and size of at least r.count.
+func (r *RingBuffer[T]) resize(size int) {
+	buf := make([]T, size)
+	r.read(buf)
+
+	r.buf = buf
+	r.start = 0
+	r.end = r.count % size
+}
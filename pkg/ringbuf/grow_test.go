@@ -0,0 +1,180 @@
+package ringbuf
+
+import (
+	"testing"
+	"testing/quick"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRingBuffer_ZeroCapacity(t *testing.T) {
+	rb := NewRingBuffer[int](0)
+
+	evicted, ok := rb.PushBack(1)
+	assert.True(t, ok)
+	assert.Equal(t, 1, evicted)
+	assert.False(t, rb.TryPushBack(2))
+	assert.Equal(t, 0, rb.Len())
+}
+
+func TestGrowableRingBuffer_Grow(t *testing.T) {
+	tests := []struct {
+		name    string
+		initCap int
+		caps    []int
+	}{
+		{"Initial capacity 0", 0, []int{1, 2, 4, 4, 8, 8, 8, 8, 16}},
+		{"Initial capacity 3", 3, []int{3, 3, 3, 6, 6, 6, 12, 12, 12}},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			rb := NewGrowableRingBuffer[int](tc.initCap)
+			for i, c := range tc.caps {
+				_, ok := rb.PushBack(i)
+				assert.False(t, ok)
+				assert.Equal(t, c, rb.Cap())
+			}
+
+			for i := range tc.caps {
+				assert.Equal(t, i, rb.PopFront())
+			}
+		})
+	}
+
+	t.Run("Keeps the order across wraparound", func(t *testing.T) {
+		rb := NewGrowableRingBuffer[int](4)
+		for i := 0; i < 4; i++ {
+			rb.PushBack(i)
+		}
+		rb.PopFront()
+		rb.PopFront()
+		for i := 4; i < 8; i++ {
+			rb.PushBack(i)
+		}
+
+		assert.Equal(t, 8, rb.Cap())
+		assert.Equal(t, []int{2, 3, 4, 5, 6, 7}, rb.Snapshot())
+	})
+}
+
+func TestGrowableRingBuffer_MaxCap(t *testing.T) {
+	rb := NewGrowableRingBuffer[int](2, WithMaxCap(5))
+	for i := 0; i < 5; i++ {
+		assert.True(t, rb.TryPushBack(i))
+	}
+	assert.Equal(t, 5, rb.Cap())
+
+	// Full at the maximum capacity
+	assert.False(t, rb.TryPushBack(5))
+	evicted, ok := rb.PushBack(5)
+	assert.True(t, ok)
+	assert.Equal(t, 0, evicted)
+	assert.Equal(t, []int{1, 2, 3, 4, 5}, rb.Snapshot())
+
+	t.Run("Initial capacity above the maximum", func(t *testing.T) {
+		rb := NewGrowableRingBuffer[int](8, WithMaxCap(2))
+		assert.Equal(t, 2, rb.Cap())
+	})
+}
+
+func TestGrowableRingBuffer_Shrink(t *testing.T) {
+	rb := NewGrowableRingBuffer[int](2, WithShrink())
+	for i := 0; i < 16; i++ {
+		rb.PushBack(i)
+	}
+	assert.Equal(t, 16, rb.Cap())
+
+	caps := []int{16, 16, 16, 16, 16, 16, 16, 16, 16, 16, 16, 8, 8, 4, 2, 2}
+	for i, c := range caps {
+		assert.Equal(t, i, rb.PopFront())
+		assert.Equal(t, c, rb.Cap())
+	}
+
+	t.Run("Not without WithShrink", func(t *testing.T) {
+		rb := NewGrowableRingBuffer[int](2)
+		for i := 0; i < 16; i++ {
+			rb.PushBack(i)
+		}
+		for i := 0; i < 16; i++ {
+			rb.PopFront()
+		}
+		assert.Equal(t, 16, rb.Cap())
+	})
+}
+
+// TestGrowableRingBuffer_Model checks random sequences of operations against
+// a slice-based reference model of a bounded FIFO queue.
+func TestGrowableRingBuffer_Model(t *testing.T) {
+	check := func(initCap, maxCap uint8, ops []byte) bool {
+		var (
+			size  = int(maxCap%32) + 1
+			rb    = NewGrowableRingBuffer[int](int(initCap%8), WithMaxCap(size), WithShrink())
+			model []int
+		)
+		for i, op := range ops {
+			switch op % 3 {
+			case 0:
+				evicted, ok := rb.PushBack(i)
+				if len(model) == size {
+					if !ok || evicted != model[0] {
+						return false
+					}
+					model = model[1:]
+				} else if ok {
+					return false
+				}
+				model = append(model, i)
+			case 1:
+				if rb.TryPushBack(i) != (len(model) < size) {
+					return false
+				}
+				if len(model) < size {
+					model = append(model, i)
+				}
+			case 2:
+				if len(model) == 0 {
+					continue
+				}
+				if rb.PopFront() != model[0] {
+					return false
+				}
+				model = model[1:]
+			}
+
+			if rb.Len() != len(model) || rb.Cap() > size {
+				return false
+			}
+			for j, item := range model {
+				if rb.At(j) != item {
+					return false
+				}
+			}
+		}
+
+		return true
+	}
+
+	if err := quick.Check(check, nil); err != nil {
+		t.Error(err)
+	}
+}
+
+func Benchmark_GrowablePushBack(b *testing.B) {
+	rb := NewGrowableRingBuffer[int](0)
+
+	for i := 0; i < b.N; i++ {
+		rb.PushBack(i)
+	}
+}
+
+func Benchmark_GrowableFIFO(b *testing.B) {
+	rb := NewGrowableRingBuffer[int](0, WithShrink())
+
+	for i := 0; i < b.N; i++ {
+		rb.PushBack(i)
+	}
+	for i := 0; i < b.N; i++ {
+		rb.PopFront()
+	}
+}
@@ -0,0 +1,27 @@
+package ringbuf
+
+import (
+	"os"
+	"syscall"
+	"unsafe"
+)
+
+// mmap maps the first size bytes of f into memory, shared with the file.
+func mmap(f *os.File, size int) ([]byte, error) {
+	return syscall.Mmap(int(f.Fd()), 0, size, syscall.PROT_READ|syscall.PROT_WRITE, syscall.MAP_SHARED)
+}
+
+func munmap(b []byte) error {
+	return syscall.Munmap(b)
+}
+
+// msync flushes the writes to the mapping b to the file, and waits for
+// them to complete.
+func msync(b []byte) error {
+	_, _, errno := syscall.Syscall(syscall.SYS_MSYNC, uintptr(unsafe.Pointer(&b[0])), uintptr(len(b)), syscall.MS_SYNC)
+	if errno != 0 {
+		return errno
+	}
+
+	return nil
+}
@@ -0,0 +1,24 @@
+//go:build !linux
+
+package ringbuf
+
+import (
+	"errors"
+	"os"
+)
+
+// errNoMmap is returned by OpenPersistentRingBuffer on platforms where it
+// does not map files into memory yet.
+var errNoMmap = errors.New("persistent ring buffers are only supported on linux")
+
+func mmap(*os.File, int) ([]byte, error) {
+	return nil, errNoMmap
+}
+
+func munmap([]byte) error {
+	return errNoMmap
+}
+
+func msync([]byte) error {
+	return errNoMmap
+}
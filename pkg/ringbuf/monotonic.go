@@ -0,0 +1,76 @@
+package ringbuf
+
+// MonotonicDeque tracks the minimum, or any other extreme, of a sliding
+// window in O(1) amortised time per element.
+//
+// Elements enter the window at the back with Push and leave it from the front
+// with PopFront, and Front returns the least element of the window by less:
+// its minimum if less is a < b, or its maximum if less is a > b. A
+// MonotonicDeque is not safe for concurrent use.
+type MonotonicDeque[T any] struct {
+	less func(a, b T) bool
+
+	// items holds the elements of the window that may become its least
+	// element, in order of both their positions and less: those with no
+	// lesser or equal element after them. Older elements are dropped as
+	// soon as an element that is not less is pushed.
+	items *RingBuffer[sequenced[T]]
+
+	// pushed and popped count the elements that entered and left the
+	// window, so that the position of the oldest element of the window is
+	// popped.
+	pushed, popped uint64
+}
+
+// sequenced is an element of a MonotonicDeque with its position in the
+// window.
+type sequenced[T any] struct {
+	seq  uint64
+	item T
+}
+
+// NewMonotonicDeque creates a new empty MonotonicDeque ordered by less.
+func NewMonotonicDeque[T any](less func(a, b T) bool) *MonotonicDeque[T] {
+	return &MonotonicDeque[T]{
+		less:  less,
+		items: NewGrowableRingBuffer[sequenced[T]](0),
+	}
+}
+
+// Push appends an element to the back of the window.
+func (d *MonotonicDeque[T]) Push(item T) {
+	// The elements not less than item cannot be the least element of the
+	// window anymore: they leave it before item does.
+	for d.items.Len() > 0 && !d.less(d.items.Back().item, item) {
+		d.items.PopBack()
+	}
+	d.items.PushBack(sequenced[T]{seq: d.pushed, item: item})
+	d.pushed++
+}
+
+// PopFront removes the element at the front of the window, the oldest one.
+// If the window is empty, the call panics
+func (d *MonotonicDeque[T]) PopFront() {
+	if d.pushed == d.popped {
+		panic("ringbuf: PopFront() called in an empty window")
+	}
+	if d.items.Front().seq == d.popped {
+		d.items.PopFront()
+	}
+	d.popped++
+}
+
+// Front returns the least element of the window.
+// If the window is empty, the call panics
+func (d *MonotonicDeque[T]) Front() T {
+	if d.pushed == d.popped {
+		panic("ringbuf: Front() called in an empty window")
+	}
+
+	return d.items.Front().item
+}
+
+// Len returns the number of elements in the window.
+func (d *MonotonicDeque[T]) Len() int {
+	return int(d.pushed - d.popped)
+}
@@ -0,0 +1,112 @@
+package ringbuf
+
+import (
+	"testing"
+	"testing/quick"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMonotonicDeque(t *testing.T) {
+	var (
+		lows  = NewMonotonicDeque(func(a, b int) bool { return a < b })
+		highs = NewMonotonicDeque(func(a, b int) bool { return a > b })
+	)
+	tests := []struct {
+		push      int
+		pop       bool
+		low, high int
+	}{
+		{push: 5, low: 5, high: 5},
+		{push: 3, low: 3, high: 5},
+		{push: 3, low: 3, high: 5},
+		// Window: 3 3 8
+		{push: 8, pop: true, low: 3, high: 8},
+		// Window: 3 8 4
+		{push: 4, pop: true, low: 3, high: 8},
+		// Window: 8 4 9
+		{push: 9, pop: true, low: 4, high: 9},
+		// Window: 4 9 1
+		{push: 1, pop: true, low: 1, high: 9},
+	}
+
+	for _, tc := range tests {
+		if tc.pop {
+			lows.PopFront()
+			highs.PopFront()
+		}
+		lows.Push(tc.push)
+		highs.Push(tc.push)
+
+		assert.Equal(t, tc.low, lows.Front(), "low after pushing %d", tc.push)
+		assert.Equal(t, tc.high, highs.Front(), "high after pushing %d", tc.push)
+	}
+	assert.Equal(t, 3, lows.Len())
+
+	t.Run("Panics when window empty", func(t *testing.T) {
+		d := NewMonotonicDeque(func(a, b int) bool { return a < b })
+		d.Push(1)
+		d.PopFront()
+		assert.Panics(t, func() { d.Front() })
+		assert.Panics(t, func() { d.PopFront() })
+	})
+}
+
+// TestMonotonicDeque_Model checks random sliding windows against their
+// minimum found by scanning.
+func TestMonotonicDeque_Model(t *testing.T) {
+	check := func(width uint8, items []int8) bool {
+		var (
+			w = int(width%16) + 1
+			d = NewMonotonicDeque(func(a, b int8) bool { return a < b })
+		)
+		for i, item := range items {
+			if d.Len() == w {
+				d.PopFront()
+			}
+			d.Push(item)
+
+			low := item
+			for _, prev := range items[max(0, i-w+1):i] {
+				if prev < low {
+					low = prev
+				}
+			}
+			if d.Front() != low || d.Len() != min(i+1, w) {
+				return false
+			}
+		}
+
+		return true
+	}
+
+	if err := quick.Check(check, nil); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestRingBuffer_PopBack(t *testing.T) {
+	rb := NewRingBuffer[int](3)
+	rb.PushSlice([]int{1, 2, 3, 4})
+
+	assert.Equal(t, 4, rb.PopBack())
+	rb.PushBack(5)
+	assert.Equal(t, []int{2, 3, 5}, rb.Snapshot())
+	assert.Equal(t, 5, rb.PopBack())
+	assert.Equal(t, 3, rb.PopBack())
+	assert.Equal(t, 2, rb.PopBack())
+	assert.Panics(t, func() { rb.PopBack() })
+}
+
+func Benchmark_MonotonicDeque(b *testing.B) {
+	d := NewMonotonicDeque(func(a, b int) bool { return a < b })
+
+	for i := 0; i < b.N; i++ {
+		if d.Len() == benchmarkQueueSize {
+			d.PopFront()
+		}
+		// A sawtooth keeps some elements in the deque.
+		d.Push(i % 100)
+		d.Front()
+	}
+}
@@ -0,0 +1,375 @@
+package ringbuf
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"os"
+	"sync"
+)
+
+var (
+	// ErrInvalidFile is returned by OpenPersistentRingBuffer for files that
+	// are not persistent ring buffers, or whose layout does not match the
+	// given capacity and record size.
+	ErrInvalidFile = errors.New("invalid ring buffer file")
+	// ErrRecordSize is returned by PersistentRingBuffer.PushBack for records
+	// of the wrong size.
+	ErrRecordSize = errors.New("record size mismatch")
+)
+
+// persistentMagic identifies the files of persistent ring buffers, and
+// their version.
+const persistentMagic = "RINGBUF1"
+
+// The file of a persistent ring buffer starts with a header:
+//
+//	magic       [8]byte
+//	record size uint32
+//	capacity    uint32
+//	state       [2][stateSize]byte
+//
+// followed by capacity slots of slotHeaderSize+record size bytes:
+//
+//	seq         uint64
+//	crc         uint32, of seq and the record
+//	record      [record size]byte
+//
+// The state of the queue is written to the two state copies in turn, so
+// that one of them is whole if the other one is torn:
+//
+//	version     uint64, the number of state updates
+//	first       uint64, the sequence number of the oldest record
+//	count       uint32
+//	crc         uint32, of the fields above
+//
+// All integers are little-endian.
+const (
+	fileHeaderSize = 64
+	stateOffset    = 16
+	stateSize      = 24
+	slotHeaderSize = 12
+)
+
+// PersistentRingBuffer is a ring buffer of fixed-size records in a
+// memory-mapped file, so that the last records pushed survive a crash or a
+// restart of the process without a separate snapshot step.
+//
+// Records are written to the file as they are pushed, and the state of the
+// queue after them. Both are checksummed, so that records torn by a crash
+// are discarded when the file is opened again. Writes reach the file when
+// the process crashes, but Sync must be called for them to survive the
+// crash of the system.
+//
+// Like RingBuffer, when it is full, PushBack overwrites the oldest record.
+type PersistentRingBuffer struct {
+	mu sync.Mutex
+
+	f    *os.File
+	data []byte
+
+	recordSize int
+	capacity   int
+
+	// version, first and count are the state of the queue, as last written
+	// to the file.
+	version uint64
+	first   uint64
+	count   int
+
+	discarded int
+}
+
+// OpenPersistentRingBuffer opens the persistent ring buffer in the file at
+// path, holding up to capacity records of recordSize bytes, and creates it
+// if it does not exist or a crash while creating it left it uninitialised.
+//
+// The records of an existing file are recovered in order, discarding the
+// ones torn by a crash. See Discarded.
+func OpenPersistentRingBuffer(path string, capacity, recordSize int) (*PersistentRingBuffer, error) {
+	if capacity < 1 || recordSize < 1 {
+		return nil, fmt.Errorf("%w: capacity %d and record size %d must be positive", ErrInvalidFile, capacity, recordSize)
+	}
+
+	//nolint:gosec // The path is the caller's
+	f, err := os.OpenFile(path, os.O_RDWR|os.O_CREATE, 0o644)
+	if err != nil {
+		return nil, err
+	}
+
+	r := &PersistentRingBuffer{
+		f:          f,
+		recordSize: recordSize,
+		capacity:   capacity,
+	}
+	if err := r.open(); err != nil {
+		f.Close()
+		return nil, err
+	}
+
+	return r, nil
+}
+
+// open maps the file, initialising it if it is empty, and recovers its
+// records.
+func (r *PersistentRingBuffer) open() error {
+	info, err := r.f.Stat()
+	if err != nil {
+		return err
+	}
+
+	size := fileHeaderSize + r.capacity*(slotHeaderSize+r.recordSize)
+	created := info.Size() == 0
+	if created {
+		if err := r.f.Truncate(int64(size)); err != nil {
+			return err
+		}
+	} else if info.Size() != int64(size) {
+		return fmt.Errorf("%w: %d bytes, expected %d", ErrInvalidFile, info.Size(), size)
+	}
+
+	if r.data, err = mmap(r.f, size); err != nil {
+		return err
+	}
+
+	// A crash while creating the file may leave it zero-filled, or with the
+	// header partially written, but the magic is written last.
+	if created || allZero(r.data[:len(persistentMagic)]) {
+		binary.LittleEndian.PutUint32(r.data[8:], uint32(r.recordSize))
+		binary.LittleEndian.PutUint32(r.data[12:], uint32(r.capacity))
+		r.writeState()
+		copy(r.data, persistentMagic)
+		return nil
+	}
+
+	return r.recover()
+}
+
+// allZero reports whether every byte of b is zero.
+func allZero(b []byte) bool {
+	for _, c := range b {
+		if c != 0 {
+			return false
+		}
+	}
+
+	return true
+}
+
+// recover reads the state of the queue and validates its records. It must
+// be called with r.mu held, or before r is shared.
+func (r *PersistentRingBuffer) recover() error {
+	if string(r.data[:len(persistentMagic)]) != persistentMagic ||
+		binary.LittleEndian.Uint32(r.data[8:]) != uint32(r.recordSize) ||
+		binary.LittleEndian.Uint32(r.data[12:]) != uint32(r.capacity) {
+		munmap(r.data)
+		return fmt.Errorf("%w: bad header", ErrInvalidFile)
+	}
+
+	// The newest whole state copy is the state of the queue.
+	found := false
+	for i := 0; i < 2; i++ {
+		version, first, count, ok := r.readState(i)
+		if ok && count <= r.capacity && (!found || version > r.version) {
+			r.version, r.first, r.count = version, first, count
+			found = true
+		}
+	}
+	if !found {
+		munmap(r.data)
+		return fmt.Errorf("%w: no valid state", ErrInvalidFile)
+	}
+
+	// A crash while pushing may leave the state pointing to a record that
+	// was not written whole, or to a record the push was overwriting.
+	// Records are only kept from the oldest valid one to the first invalid
+	// one after it, so that the queue stays in order.
+	valid := 0
+	for valid < r.count && !r.validRecord(r.first+uint64(valid)) {
+		valid++
+	}
+	skipped := valid
+	for valid < r.count && r.validRecord(r.first+uint64(valid)) {
+		valid++
+	}
+	if kept := valid - skipped; kept != r.count {
+		r.discarded = r.count - kept
+		r.first += uint64(skipped)
+		r.count = kept
+		r.writeState()
+	}
+
+	return nil
+}
+
+// PushBack appends a record to the back of the queue, overwriting the oldest
+// one if the ring buffer is full. It returns ErrRecordSize if the record is
+// not of the ring buffer's record size.
+func (r *PersistentRingBuffer) PushBack(record []byte) error {
+	if len(record) != r.recordSize {
+		return fmt.Errorf("%w: %d bytes, expected %d", ErrRecordSize, len(record), r.recordSize)
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	seq := r.first + uint64(r.count)
+	slot := r.slot(seq)
+	binary.LittleEndian.PutUint64(slot, seq)
+	copy(slot[slotHeaderSize:], record)
+	binary.LittleEndian.PutUint32(slot[8:], r.checksum(slot))
+
+	if r.count == r.capacity {
+		r.first++
+	} else {
+		r.count++
+	}
+	r.writeState()
+
+	return nil
+}
+
+// PopFront removes and returns the record at the front of the queue.
+// If the ring buffer is empty, the call panics
+func (r *PersistentRingBuffer) PopFront() []byte {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.count <= 0 {
+		panic("ringbuf: PopFront() called in an empty buffer")
+	}
+	record := r.record(r.first)
+	r.first++
+	r.count--
+	r.writeState()
+
+	return record
+}
+
+// At returns a copy of the i-th record of the queue, counting from the
+// front, without removing it.
+// If i is out of range, the call panics
+func (r *PersistentRingBuffer) At(i int) []byte {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if i < 0 || i >= r.count {
+		panic("ringbuf: At() index out of range")
+	}
+
+	return r.record(r.first + uint64(i))
+}
+
+// Snapshot returns a copy of the records of the queue, from front to back.
+func (r *PersistentRingBuffer) Snapshot() [][]byte {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	records := make([][]byte, 0, r.count)
+	for i := 0; i < r.count; i++ {
+		records = append(records, r.record(r.first+uint64(i)))
+	}
+
+	return records
+}
+
+// Len returns the number of records currently stored in the queue.
+func (r *PersistentRingBuffer) Len() int {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	return r.count
+}
+
+// Cap returns the number of records the queue can hold.
+func (r *PersistentRingBuffer) Cap() int {
+	return r.capacity
+}
+
+// Discarded returns the number of records discarded when the file was
+// opened, for being torn or overwritten by a crash.
+func (r *PersistentRingBuffer) Discarded() int {
+	return r.discarded
+}
+
+// Sync flushes the writes to the file to the storage device.
+func (r *PersistentRingBuffer) Sync() error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	return msync(r.data)
+}
+
+// Close unmaps and closes the file. The ring buffer must not be used
+// afterwards.
+func (r *PersistentRingBuffer) Close() error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	err := munmap(r.data)
+	r.data = nil
+	if cerr := r.f.Close(); err == nil {
+		err = cerr
+	}
+
+	return err
+}
+
+// slot returns the bytes of the slot of the record with sequence number seq.
+func (r *PersistentRingBuffer) slot(seq uint64) []byte {
+	size := slotHeaderSize + r.recordSize
+	offset := fileHeaderSize + int(seq%uint64(r.capacity))*size
+
+	return r.data[offset : offset+size]
+}
+
+// record returns a copy of the record with sequence number seq.
+func (r *PersistentRingBuffer) record(seq uint64) []byte {
+	record := make([]byte, r.recordSize)
+	copy(record, r.slot(seq)[slotHeaderSize:])
+
+	return record
+}
+
+// validRecord reports whether the slot of the record with sequence number
+// seq holds it whole.
+func (r *PersistentRingBuffer) validRecord(seq uint64) bool {
+	slot := r.slot(seq)
+
+	return binary.LittleEndian.Uint64(slot) == seq &&
+		binary.LittleEndian.Uint32(slot[8:]) == r.checksum(slot)
+}
+
+// checksum returns the checksum of a slot: of its sequence number and
+// record.
+func (r *PersistentRingBuffer) checksum(slot []byte) uint32 {
+	crc := crc32.ChecksumIEEE(slot[:8])
+
+	return crc32.Update(crc, crc32.IEEETable, slot[slotHeaderSize:])
+}
+
+// writeState writes the state of the queue to the older state copy.
+func (r *PersistentRingBuffer) writeState() {
+	r.version++
+	state := r.state(int(r.version % 2))
+	binary.LittleEndian.PutUint64(state, r.version)
+	binary.LittleEndian.PutUint64(state[8:], r.first)
+	binary.LittleEndian.PutUint32(state[16:], uint32(r.count))
+	binary.LittleEndian.PutUint32(state[20:], crc32.ChecksumIEEE(state[:20]))
+}
+
+// readState reads the i-th state copy, and reports whether it is whole.
+func (r *PersistentRingBuffer) readState(i int) (version, first uint64, count int, ok bool) {
+	state := r.state(i)
+	ok = binary.LittleEndian.Uint32(state[20:]) == crc32.ChecksumIEEE(state[:20])
+
+	return binary.LittleEndian.Uint64(state),
+		binary.LittleEndian.Uint64(state[8:]),
+		int(binary.LittleEndian.Uint32(state[16:])),
+		ok
+}
+
+// state returns the bytes of the i-th state copy.
+func (r *PersistentRingBuffer) state(i int) []byte {
+	offset := stateOffset + i*stateSize
+
+	return r.data[offset : offset+stateSize]
+}
@@ -0,0 +1,214 @@
+//go:build linux
+
+package ringbuf
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// tmpfsPath returns the path of a file in a temporary directory, on tmpfs
+// if /dev/shm is available.
+func tmpfsPath(t *testing.T) string {
+	t.Helper()
+
+	dir, err := os.MkdirTemp("/dev/shm", "ringbuf")
+	if err != nil {
+		dir = t.TempDir()
+	} else {
+		t.Cleanup(func() { os.RemoveAll(dir) })
+	}
+
+	return filepath.Join(dir, "ringbuf")
+}
+
+// corrupt flips a byte of the file at path at offset.
+func corrupt(t *testing.T, path string, offset int64) {
+	t.Helper()
+
+	f, err := os.OpenFile(path, os.O_RDWR, 0)
+	assert.NoError(t, err)
+	defer f.Close()
+
+	b := make([]byte, 1)
+	_, err = f.ReadAt(b, offset)
+	assert.NoError(t, err)
+	b[0] ^= 0xff
+	_, err = f.WriteAt(b, offset)
+	assert.NoError(t, err)
+}
+
+// records returns the records of r as strings.
+func records(r *PersistentRingBuffer) []string {
+	var rs []string
+	for _, b := range r.Snapshot() {
+		rs = append(rs, string(b))
+	}
+
+	return rs
+}
+
+// pushAll pushes every record and closes r.
+func pushAll(t *testing.T, r *PersistentRingBuffer, rs ...string) {
+	t.Helper()
+
+	for _, record := range rs {
+		assert.NoError(t, r.PushBack([]byte(record)))
+	}
+	assert.NoError(t, r.Sync())
+	assert.NoError(t, r.Close())
+}
+
+func TestPersistentRingBuffer(t *testing.T) {
+	t.Run("Survives reopening", func(t *testing.T) {
+		path := tmpfsPath(t)
+		r, err := OpenPersistentRingBuffer(path, 3, 2)
+		assert.NoError(t, err)
+		assert.Equal(t, 0, r.Len())
+		pushAll(t, r, "aa", "bb")
+
+		r, err = OpenPersistentRingBuffer(path, 3, 2)
+		assert.NoError(t, err)
+		assert.Equal(t, []string{"aa", "bb"}, records(r))
+		assert.Equal(t, 0, r.Discarded())
+
+		assert.Equal(t, "aa", string(r.PopFront()))
+		pushAll(t, r, "cc", "dd", "ee")
+
+		r, err = OpenPersistentRingBuffer(path, 3, 2)
+		assert.NoError(t, err)
+		defer r.Close()
+		// Overwritten when full
+		assert.Equal(t, []string{"cc", "dd", "ee"}, records(r))
+		assert.Equal(t, "dd", string(r.At(1)))
+		assert.Equal(t, 3, r.Cap())
+	})
+
+	t.Run("Record size", func(t *testing.T) {
+		r, err := OpenPersistentRingBuffer(tmpfsPath(t), 3, 2)
+		assert.NoError(t, err)
+		defer r.Close()
+
+		assert.ErrorIs(t, r.PushBack([]byte("a")), ErrRecordSize)
+		assert.Equal(t, 0, r.Len())
+	})
+
+	t.Run("Layout mismatch", func(t *testing.T) {
+		path := tmpfsPath(t)
+		r, err := OpenPersistentRingBuffer(path, 3, 2)
+		assert.NoError(t, err)
+		assert.NoError(t, r.Close())
+
+		_, err = OpenPersistentRingBuffer(path, 3, 4)
+		assert.ErrorIs(t, err, ErrInvalidFile)
+		_, err = OpenPersistentRingBuffer(path, 0, 2)
+		assert.ErrorIs(t, err, ErrInvalidFile)
+	})
+
+	t.Run("Crash while creating", func(t *testing.T) {
+		path := tmpfsPath(t)
+		// Truncated to its size, but nothing written
+		assert.NoError(t, os.WriteFile(path, make([]byte, fileHeaderSize+3*(slotHeaderSize+2)), 0o600))
+
+		r, err := OpenPersistentRingBuffer(path, 3, 2)
+		assert.NoError(t, err)
+		assert.Equal(t, 0, r.Len())
+		pushAll(t, r, "aa")
+
+		r, err = OpenPersistentRingBuffer(path, 3, 2)
+		assert.NoError(t, err)
+		defer r.Close()
+		assert.Equal(t, []string{"aa"}, records(r))
+	})
+
+	t.Run("Not a ring buffer file", func(t *testing.T) {
+		path := tmpfsPath(t)
+		r, err := OpenPersistentRingBuffer(path, 1, 1)
+		assert.NoError(t, err)
+		assert.NoError(t, r.Close())
+		corrupt(t, path, 0)
+
+		_, err = OpenPersistentRingBuffer(path, 1, 1)
+		assert.ErrorIs(t, err, ErrInvalidFile)
+	})
+}
+
+func TestPersistentRingBuffer_Recovery(t *testing.T) {
+	const (
+		capacity   = 4
+		recordSize = 2
+		slotSize   = slotHeaderSize + recordSize
+	)
+	// slotOffset returns the offset of the slot of the record with sequence
+	// number seq.
+	slotOffset := func(seq int) int64 {
+		return int64(fileHeaderSize + seq%capacity*slotSize)
+	}
+
+	tests := []struct {
+		name      string
+		pushed    []string
+		offset    int64
+		exp       []string
+		discarded int
+	}{
+		{
+			"Torn newest record",
+			[]string{"aa", "bb", "cc"},
+			slotOffset(2) + slotHeaderSize,
+			[]string{"aa", "bb"},
+			1,
+		},
+		{
+			"Oldest record being overwritten",
+			[]string{"aa", "bb", "cc", "dd", "ee"},
+			// The sequence number of the oldest record, "bb"
+			slotOffset(1),
+			[]string{"cc", "dd", "ee"},
+			1,
+		},
+		{
+			// Creating the file and 3 pushes are 4 state updates: the
+			// newest state copy is the first one.
+			"Torn state",
+			[]string{"aa", "bb", "cc"},
+			stateOffset + 8,
+			[]string{"aa", "bb"},
+			0,
+		},
+		{
+			"Torn record in the middle",
+			[]string{"aa", "bb", "cc", "dd"},
+			slotOffset(1) + 8,
+			[]string{"aa"},
+			3,
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			path := tmpfsPath(t)
+			r, err := OpenPersistentRingBuffer(path, capacity, recordSize)
+			assert.NoError(t, err)
+			pushAll(t, r, tc.pushed...)
+
+			corrupt(t, path, tc.offset)
+
+			r, err = OpenPersistentRingBuffer(path, capacity, recordSize)
+			assert.NoError(t, err)
+			assert.Equal(t, tc.exp, records(r))
+			assert.Equal(t, tc.discarded, r.Discarded())
+			pushAll(t, r, "zz")
+
+			// The recovered state was written back
+			r, err = OpenPersistentRingBuffer(path, capacity, recordSize)
+			assert.NoError(t, err)
+			defer r.Close()
+			assert.Equal(t, append(tc.exp, "zz"), records(r))
+			assert.Equal(t, 0, r.Discarded())
+		})
+	}
+}
@@ -0,0 +1,248 @@
+package ringbuf
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+)
+
+var (
+	// ErrClosed is returned by the methods of a Queue that was closed: by
+	// the pushes, and by the pops once it is empty.
+	ErrClosed = errors.New("queue closed")
+	// ErrFull is returned by Queue.TryPush while the queue is full.
+	ErrFull = errors.New("queue full")
+	// ErrEmpty is returned by Queue.TryPop while the queue is empty.
+	ErrEmpty = errors.New("queue empty")
+
+	// ErrInvalidCapacity is returned by NewQueue for capacities below 1.
+	ErrInvalidCapacity = errors.New("capacity must be positive")
+	// ErrInvalidWatermarks is returned by NewQueue for watermarks that are
+	// not 0 <= low < high <= capacity.
+	ErrInvalidWatermarks = errors.New("watermarks must be 0 <= low < high <= capacity")
+)
+
+// QueueOption configures a Queue.
+type QueueOption func(*queueConfig)
+
+// queueConfig holds the options of a Queue.
+type queueConfig struct {
+	low, high     int
+	onHigh, onLow func(depth int)
+}
+
+// WithWatermarks calls onHigh when the depth of the queue rises to high, and
+// onLow when it falls back to low afterwards, e.g. to report backpressure.
+// Either callback may be nil.
+//
+// The callbacks alternate: onHigh is not called again before onLow. They are
+// called by the goroutine pushing or popping, without the queue locked.
+func WithWatermarks(low, high int, onHigh, onLow func(depth int)) QueueOption {
+	return func(c *queueConfig) {
+		c.low, c.high = low, high
+		c.onHigh, c.onLow = onHigh, onLow
+	}
+}
+
+// Queue is a bounded FIFO queue on a RingBuffer for goroutines to hand
+// elements to each other, like a buffered channel whose depth is visible.
+//
+// Push and Pop block while the queue is full or empty, until the context is
+// done. Like a channel, a Queue can be closed, after which the elements left
+// can still be popped.
+type Queue[T any] struct {
+	mu sync.Mutex
+
+	rb     *RingBuffer[T]
+	closed bool
+
+	// changed is closed, and reset, when an element is pushed or popped or
+	// the queue is closed, to wake up the blocked Push and Pop calls. It is
+	// only created while there are any.
+	changed chan struct{}
+
+	queueConfig
+	// aboveHigh is set once the depth rose to the high watermark, until it
+	// falls back to the low one.
+	aboveHigh bool
+}
+
+// NewQueue creates a new Queue holding up to capacity elements.
+func NewQueue[T any](capacity int, opts ...QueueOption) (*Queue[T], error) {
+	if capacity < 1 {
+		return nil, fmt.Errorf("%w: %d", ErrInvalidCapacity, capacity)
+	}
+
+	q := &Queue[T]{
+		rb: NewRingBuffer[T](capacity),
+	}
+	for _, opt := range opts {
+		opt(&q.queueConfig)
+	}
+	if (q.onHigh != nil || q.onLow != nil) && (q.low < 0 || q.low >= q.high || q.high > capacity) {
+		return nil, fmt.Errorf("%w: low=%d high=%d capacity=%d", ErrInvalidWatermarks, q.low, q.high, capacity)
+	}
+
+	return q, nil
+}
+
+// TryPush appends an element to the back of the queue. It returns ErrFull if
+// there is no room for it, or ErrClosed if the queue was closed.
+func (q *Queue[T]) TryPush(item T) error {
+	q.mu.Lock()
+	notify, err := q.tryPush(item)
+	q.mu.Unlock()
+	notify()
+
+	return err
+}
+
+// TryPop removes and returns the element from the front of the queue. It
+// returns ErrEmpty if there is none, or ErrClosed if the queue was also
+// closed.
+func (q *Queue[T]) TryPop() (T, error) {
+	q.mu.Lock()
+	item, notify, err := q.tryPop()
+	q.mu.Unlock()
+	notify()
+
+	return item, err
+}
+
+// Push appends an element to the back of the queue, waiting for room while
+// it is full. It returns ErrClosed if the queue is closed, or the context's
+// error if it is done first.
+func (q *Queue[T]) Push(ctx context.Context, item T) error {
+	for {
+		q.mu.Lock()
+		notify, err := q.tryPush(item)
+		changed := q.wait(err, ErrFull)
+		q.mu.Unlock()
+		notify()
+		if changed == nil {
+			return err
+		}
+
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-changed:
+		}
+	}
+}
+
+// Pop removes and returns the element from the front of the queue, waiting
+// for one while it is empty. It returns ErrClosed if the queue is closed and
+// empty, or the context's error if it is done first.
+func (q *Queue[T]) Pop(ctx context.Context) (T, error) {
+	for {
+		q.mu.Lock()
+		item, notify, err := q.tryPop()
+		changed := q.wait(err, ErrEmpty)
+		q.mu.Unlock()
+		notify()
+		if changed == nil {
+			return item, err
+		}
+
+		select {
+		case <-ctx.Done():
+			return item, ctx.Err()
+		case <-changed:
+		}
+	}
+}
+
+// Close closes the queue: the blocked and further pushes return ErrClosed,
+// and the pops do too once the elements left are popped. Unlike closing a
+// channel, closing a Queue more than once is harmless.
+func (q *Queue[T]) Close() {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	q.closed = true
+	q.broadcast()
+}
+
+// Len returns the number of elements currently stored in the queue.
+func (q *Queue[T]) Len() int {
+	return q.rb.Len()
+}
+
+// Cap returns the number of elements the queue can hold.
+func (q *Queue[T]) Cap() int {
+	return q.rb.Cap()
+}
+
+// tryPush must be called with q.mu held. It returns the watermark callback
+// to call once q.mu is released.
+func (q *Queue[T]) tryPush(item T) (func(), error) {
+	if q.closed {
+		return func() {}, ErrClosed
+	}
+	if !q.rb.TryPushBack(item) {
+		return func() {}, ErrFull
+	}
+	q.broadcast()
+
+	return q.watermark(), nil
+}
+
+// tryPop must be called with q.mu held. It returns the watermark callback to
+// call once q.mu is released.
+func (q *Queue[T]) tryPop() (T, func(), error) {
+	if q.rb.Len() == 0 {
+		var zero T
+		if q.closed {
+			return zero, func() {}, ErrClosed
+		}
+		return zero, func() {}, ErrEmpty
+	}
+	item := q.rb.PopFront()
+	q.broadcast()
+
+	return item, q.watermark(), nil
+}
+
+// wait returns the channel to wait on before retrying if err is retry, or
+// nil otherwise. It must be called with q.mu held.
+func (q *Queue[T]) wait(err, retry error) <-chan struct{} {
+	if !errors.Is(err, retry) {
+		return nil
+	}
+	if q.changed == nil {
+		q.changed = make(chan struct{})
+	}
+
+	return q.changed
+}
+
+// broadcast wakes up the blocked Push and Pop calls. It must be called with
+// q.mu held.
+func (q *Queue[T]) broadcast() {
+	if q.changed != nil {
+		close(q.changed)
+		q.changed = nil
+	}
+}
+
+// watermark returns the callback of the watermark the depth of the queue
+// crossed, if any, or a no-op. It must be called with q.mu held.
+func (q *Queue[T]) watermark() func() {
+	n := q.rb.Len()
+	switch {
+	case !q.aboveHigh && n >= q.high && (q.onHigh != nil || q.onLow != nil):
+		q.aboveHigh = true
+		if q.onHigh != nil {
+			return func() { q.onHigh(n) }
+		}
+	case q.aboveHigh && n <= q.low:
+		q.aboveHigh = false
+		if q.onLow != nil {
+			return func() { q.onLow(n) }
+		}
+	}
+
+	return func() {}
+}
@@ -0,0 +1,188 @@
+package ringbuf
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNewQueue(t *testing.T) {
+	noop := func(int) {}
+	tests := []struct {
+		name     string
+		capacity int
+		opts     []QueueOption
+		err      error
+	}{
+		{"Valid", 4, nil, nil},
+		{"Zero capacity", 0, nil, ErrInvalidCapacity},
+		{"Valid watermarks", 4, []QueueOption{WithWatermarks(1, 4, noop, noop)}, nil},
+		{"Low watermark equals high", 4, []QueueOption{WithWatermarks(2, 2, noop, nil)}, ErrInvalidWatermarks},
+		{"High watermark above capacity", 4, []QueueOption{WithWatermarks(1, 5, nil, noop)}, ErrInvalidWatermarks},
+		{"Negative low watermark", 4, []QueueOption{WithWatermarks(-1, 2, noop, noop)}, ErrInvalidWatermarks},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			q, err := NewQueue[int](tc.capacity, tc.opts...)
+			assert.ErrorIs(t, err, tc.err)
+			if err == nil {
+				assert.Equal(t, tc.capacity, q.Cap())
+			}
+		})
+	}
+}
+
+func TestQueue_TryPushTryPop(t *testing.T) {
+	q, err := NewQueue[int](2)
+	assert.NoError(t, err)
+
+	_, err = q.TryPop()
+	assert.ErrorIs(t, err, ErrEmpty)
+
+	assert.NoError(t, q.TryPush(1))
+	assert.NoError(t, q.TryPush(2))
+	assert.ErrorIs(t, q.TryPush(3), ErrFull)
+	assert.Equal(t, 2, q.Len())
+
+	item, err := q.TryPop()
+	assert.NoError(t, err)
+	assert.Equal(t, 1, item)
+	assert.NoError(t, q.TryPush(3))
+}
+
+func TestQueue_Close(t *testing.T) {
+	q, err := NewQueue[int](2)
+	assert.NoError(t, err)
+	assert.NoError(t, q.TryPush(1))
+
+	q.Close()
+	q.Close()
+	assert.ErrorIs(t, q.TryPush(2), ErrClosed)
+	assert.ErrorIs(t, q.Push(context.Background(), 2), ErrClosed)
+
+	// The elements left are popped first
+	item, err := q.Pop(context.Background())
+	assert.NoError(t, err)
+	assert.Equal(t, 1, item)
+
+	_, err = q.TryPop()
+	assert.ErrorIs(t, err, ErrClosed)
+	_, err = q.Pop(context.Background())
+	assert.ErrorIs(t, err, ErrClosed)
+}
+
+func TestQueue_PushPop(t *testing.T) {
+	t.Run("Cancelled while full", func(t *testing.T) {
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+		defer cancel()
+
+		q, _ := NewQueue[int](1)
+		assert.NoError(t, q.Push(ctx, 1))
+		assert.ErrorIs(t, q.Push(ctx, 2), context.DeadlineExceeded)
+		assert.Equal(t, 1, q.Len())
+	})
+
+	t.Run("Cancelled while empty", func(t *testing.T) {
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+		defer cancel()
+
+		q, _ := NewQueue[int](1)
+		_, err := q.Pop(ctx)
+		assert.ErrorIs(t, err, context.DeadlineExceeded)
+	})
+
+	t.Run("Unblocked by Close", func(t *testing.T) {
+		q, _ := NewQueue[int](1)
+		go func() {
+			time.Sleep(10 * time.Millisecond)
+			q.Close()
+		}()
+
+		_, err := q.Pop(context.Background())
+		assert.ErrorIs(t, err, ErrClosed)
+	})
+
+	// Run with -race.
+	t.Run("Producers and consumers", func(t *testing.T) {
+		const (
+			producers = 4
+			n         = 10000
+		)
+		var (
+			q, _ = NewQueue[int](8)
+			ctx  = context.Background()
+			wg   sync.WaitGroup
+			sums = make(chan int)
+		)
+		for p := 0; p < producers; p++ {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				for i := 1; i <= n; i++ {
+					assert.NoError(t, q.Push(ctx, i))
+				}
+			}()
+		}
+		for c := 0; c < 2; c++ {
+			go func() {
+				sum := 0
+				for {
+					item, err := q.Pop(ctx)
+					if err != nil {
+						assert.ErrorIs(t, err, ErrClosed)
+						sums <- sum
+						return
+					}
+					sum += item
+				}
+			}()
+		}
+
+		wg.Wait()
+		q.Close()
+		assert.Equal(t, producers*n*(n+1)/2, <-sums+<-sums)
+	})
+}
+
+func TestQueue_Watermarks(t *testing.T) {
+	var highs, lows []int
+	q, err := NewQueue[int](4, WithWatermarks(1, 3,
+		func(depth int) { highs = append(highs, depth) },
+		func(depth int) { lows = append(lows, depth) },
+	))
+	assert.NoError(t, err)
+
+	for _, op := range []string{"push", "push", "push", "pop", "push", "pop", "pop", "pop", "push", "push", "push"} {
+		if op == "push" {
+			assert.NoError(t, q.TryPush(0))
+		} else {
+			_, err := q.TryPop()
+			assert.NoError(t, err)
+		}
+	}
+
+	// Depths: 1 2 3 2 3 2 1 0 1 2 3
+	assert.Equal(t, []int{3, 3}, highs)
+	assert.Equal(t, []int{1}, lows)
+}
+
+func Benchmark_Queue(b *testing.B) {
+	var (
+		ctx  = context.Background()
+		q, _ = NewQueue[int](benchmarkQueueSize)
+	)
+
+	go func() {
+		for i := 0; i < b.N; i++ {
+			q.Push(ctx, i)
+		}
+	}()
+
+	for i := 0; i < b.N; i++ {
+		q.Pop(ctx)
+	}
+}
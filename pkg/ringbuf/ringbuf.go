@@ -6,6 +6,11 @@ import (
 
 // RingBuffer represents a single instance of the ring buffer
 // data structure.
+//
+// It holds up to its maximum capacity elements in FIFO order. When it is
+// full, PushBack overwrites the oldest element and TryPushBack rejects the
+// new one. A growable RingBuffer grows instead, up to its maximum capacity
+// if it has one. See NewGrowableRingBuffer.
 type RingBuffer[T any] struct {
 	mu sync.Mutex
 
@@ -14,6 +19,12 @@ type RingBuffer[T any] struct {
 	end    int
 	start  int
 	maxCap int
+
+	// growable buffers grow to fit the elements pushed, up to maxCap if it
+	// is positive, and shrink back down to minCap if shrink is set.
+	growable bool
+	shrink   bool
+	minCap   int
 }
 
 // NewRingBuffer creates a new RingBuffer with a given maximum capacity.
@@ -27,39 +38,171 @@ func NewRingBuffer[T any](maxCap int) *RingBuffer[T] {
 }
 
 // PushBack appends an element to the back of the queue.
-// If the ring buffer is empty, the call panics
-func (r *RingBuffer[T]) PushBack(item T) {
+// If the ring buffer is full, the element at the front of the queue is
+// discarded to make room and returned, along with true.
+func (r *RingBuffer[T]) PushBack(item T) (evicted T, ok bool) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.full() {
+		if len(r.buf) == 0 {
+			// A zero capacity buffer discards every element.
+			return item, true
+		}
+		evicted, ok = r.buf[r.start], true
+		r.start++
+		r.start %= len(r.buf)
+		r.count--
+	}
+	r.push(item)
+
+	return evicted, ok
+}
+
+// TryPushBack appends an element to the back of the queue and reports
+// whether there was room for it. If the ring buffer is full, it is left
+// unchanged.
+func (r *RingBuffer[T]) TryPushBack(item T) bool {
 	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.full() {
+		return false
+	}
+	r.push(item)
+
+	return true
+}
+
+// push appends an element to a ring buffer that is not full. It must be
+// called with r.mu held.
+func (r *RingBuffer[T]) push(item T) {
 	r.buf[r.end] = item
 	r.end++
 	r.end %= len(r.buf)
-	if r.count < r.maxCap {
-		r.count++
-	}
-	r.mu.Unlock()
+	r.count++
 }
 
 // PopFront removes and returns the element from the front of the queue.
 // If the ring buffer is empty, the call panic
 func (r *RingBuffer[T]) PopFront() T {
+	r.mu.Lock()
+	defer r.mu.Unlock()
 	if r.count <= 0 {
 		panic("ringbuf: PopFront() called in an empty buffer")
 	}
-	r.mu.Lock()
 	item := r.buf[r.start]
 	r.start++
 	r.start %= len(r.buf)
 	r.count--
-	r.mu.Unlock()
+	r.maybeShrink()
+
+	return item
+}
+
+// PopBack removes and returns the element from the back of the queue, the
+// last one pushed.
+// If the ring buffer is empty, the call panics
+func (r *RingBuffer[T]) PopBack() T {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.count <= 0 {
+		panic("ringbuf: PopBack() called in an empty buffer")
+	}
+	r.end = (r.end - 1 + len(r.buf)) % len(r.buf)
+	item := r.buf[r.end]
+	r.count--
+	r.maybeShrink()
 
 	return item
 }
 
+// Front returns the element at the front of the queue without removing it.
+// If the ring buffer is empty, the call panics
+func (r *RingBuffer[T]) Front() T {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.count <= 0 {
+		panic("ringbuf: Front() called in an empty buffer")
+	}
+
+	return r.buf[r.start]
+}
+
+// Back returns the element at the back of the queue, the last one pushed,
+// without removing it.
+// If the ring buffer is empty, the call panics
+func (r *RingBuffer[T]) Back() T {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.count <= 0 {
+		panic("ringbuf: Back() called in an empty buffer")
+	}
+
+	return r.buf[(r.start+r.count-1)%len(r.buf)]
+}
+
+// At returns the i-th element of the queue, counting from the front, without
+// removing it.
+// If i is out of range, the call panics
+func (r *RingBuffer[T]) At(i int) T {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if i < 0 || i >= r.count {
+		panic("ringbuf: At() index out of range")
+	}
+
+	return r.buf[(r.start+i)%len(r.buf)]
+}
+
+// Do calls f on each element of the queue, from front to back, without
+// removing them.
+// The ring buffer is locked meanwhile, so f must not call its methods.
+func (r *RingBuffer[T]) Do(f func(T)) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for i := 0; i < r.count; i++ {
+		f(r.buf[(r.start+i)%len(r.buf)])
+	}
+}
+
+// Snapshot returns a copy of the elements of the queue, from front to back.
+func (r *RingBuffer[T]) Snapshot() []T {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	items := make([]T, r.count)
+	r.read(items)
+
+	return items
+}
+
 // Len returns the number of elements currently stored in the queue.
 // If r is nil, r.Len() is zero.
 func (r *RingBuffer[T]) Len() int {
 	if r == nil {
 		return 0
 	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
 	return r.count
 }
+
+// Cap returns the number of elements the queue can hold before PushBack
+// overwrites or, if the ring buffer is growable, grows.
+// If r is nil, r.Cap() is zero.
+func (r *RingBuffer[T]) Cap() int {
+	if r == nil {
+		return 0
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	return len(r.buf)
+}
+
+func min(a, b int) int {
+	if a < b {
+		return a
+	}
+
+	return b
+}
@@ -3,6 +3,7 @@ package ringbuf
 import (
 	"math/rand"
 	"testing"
+	"testing/quick"
 
 	"github.com/stretchr/testify/assert"
 )
@@ -79,6 +80,95 @@ func TestRingBuffer_PushBack(t *testing.T) {
 	}
 }
 
+func TestRingBuffer_PushBackOverwrite(t *testing.T) {
+	rb := NewRingBuffer[int](2)
+
+	_, ok := rb.PushBack(1)
+	assert.False(t, ok)
+	_, ok = rb.PushBack(2)
+	assert.False(t, ok)
+
+	evicted, ok := rb.PushBack(3)
+	assert.True(t, ok)
+	assert.Equal(t, 1, evicted)
+	assert.Equal(t, 2, rb.Len())
+
+	// The oldest element left is at the front
+	assert.Equal(t, 2, rb.PopFront())
+	assert.Equal(t, 3, rb.PopFront())
+}
+
+func TestRingBuffer_TryPushBack(t *testing.T) {
+	rb := NewRingBuffer[int](2)
+
+	assert.True(t, rb.TryPushBack(1))
+	assert.True(t, rb.TryPushBack(2))
+	assert.False(t, rb.TryPushBack(3))
+	assert.Equal(t, 2, rb.Len())
+
+	assert.Equal(t, 1, rb.PopFront())
+	assert.True(t, rb.TryPushBack(3))
+	assert.Equal(t, 2, rb.PopFront())
+	assert.Equal(t, 3, rb.PopFront())
+}
+
+// TestRingBuffer_Model checks random sequences of operations against a
+// slice-based reference model of a FIFO queue.
+func TestRingBuffer_Model(t *testing.T) {
+	check := func(maxCap uint8, ops []byte) bool {
+		var (
+			size  = int(maxCap%16) + 1
+			rb    = NewRingBuffer[int](size)
+			model []int
+		)
+		for i, op := range ops {
+			switch op % 3 {
+			case 0:
+				evicted, ok := rb.PushBack(i)
+				if len(model) == size {
+					if !ok || evicted != model[0] {
+						return false
+					}
+					model = model[1:]
+				} else if ok {
+					return false
+				}
+				model = append(model, i)
+			case 1:
+				if rb.TryPushBack(i) != (len(model) < size) {
+					return false
+				}
+				if len(model) < size {
+					model = append(model, i)
+				}
+			case 2:
+				if len(model) == 0 {
+					continue
+				}
+				if rb.PopFront() != model[0] {
+					return false
+				}
+				model = model[1:]
+			}
+
+			if rb.Len() != len(model) {
+				return false
+			}
+			for j, item := range model {
+				if rb.At(j) != item {
+					return false
+				}
+			}
+		}
+
+		return true
+	}
+
+	if err := quick.Check(check, nil); err != nil {
+		t.Error(err)
+	}
+}
+
 func TestRingBuffer_PopFront(t *testing.T) {
 	t.Run("All elements taken FIFO", func(t *testing.T) {
 		var (
@@ -109,6 +199,129 @@ func TestRingBuffer_PopFront(t *testing.T) {
 	})
 }
 
+func TestRingBuffer_Front(t *testing.T) {
+	t.Run("Does not remove the element", func(t *testing.T) {
+		rb := NewRingBuffer[int](3)
+		rb.PushBack(1)
+		rb.PushBack(2)
+
+		assert.Equal(t, 1, rb.Front())
+		assert.Equal(t, 2, rb.Len())
+
+		rb.PopFront()
+		assert.Equal(t, 2, rb.Front())
+	})
+
+	t.Run("Panics when buffer empty", func(t *testing.T) {
+		defer func() {
+			if r := recover(); r == nil {
+				t.Errorf("Front() did not panic")
+			}
+		}()
+		rb := NewRingBuffer[int](1)
+		rb.Front()
+	})
+}
+
+func TestRingBuffer_At(t *testing.T) {
+	t.Run("Counts from the front across wraparound", func(t *testing.T) {
+		rb := NewRingBuffer[int](3)
+		for i := 1; i <= 4; i++ {
+			rb.PushBack(i)
+			if rb.Len() == 3 {
+				rb.PopFront()
+			}
+		}
+
+		assert.Equal(t, 3, rb.At(0))
+		assert.Equal(t, 4, rb.At(1))
+	})
+
+	t.Run("Panics when out of range", func(t *testing.T) {
+		for _, i := range []int{-1, 1} {
+			func() {
+				defer func() {
+					if r := recover(); r == nil {
+						t.Errorf("At(%d) did not panic", i)
+					}
+				}()
+				rb := NewRingBuffer[int](2)
+				rb.PushBack(1)
+				rb.At(i)
+			}()
+		}
+	})
+}
+
+func TestRingBuffer_Back(t *testing.T) {
+	t.Run("Last element pushed across wraparound", func(t *testing.T) {
+		rb := NewRingBuffer[int](2)
+		for i := 1; i <= 3; i++ {
+			rb.PushBack(i)
+			assert.Equal(t, i, rb.Back())
+		}
+		assert.Equal(t, 2, rb.Len())
+	})
+
+	t.Run("Panics when buffer empty", func(t *testing.T) {
+		defer func() {
+			if r := recover(); r == nil {
+				t.Errorf("Back() did not panic")
+			}
+		}()
+		rb := NewRingBuffer[int](1)
+		rb.Back()
+	})
+}
+
+func TestRingBuffer_DoSnapshot(t *testing.T) {
+	tests := []struct {
+		name   string
+		maxCap int
+		data   []int
+		exp    []int
+	}{
+		{"Empty", 3, nil, []int{}},
+		{"Partially full", 3, []int{1, 2}, []int{1, 2}},
+		{"Full", 3, []int{1, 2, 3}, []int{1, 2, 3}},
+		{"Wrapped around", 3, []int{1, 2, 3, 4, 5}, []int{3, 4, 5}},
+		{"Zero capacity", 0, nil, []int{}},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			rb := NewRingBuffer[int](tc.maxCap)
+			for _, item := range tc.data {
+				rb.PushBack(item)
+			}
+
+			items := []int{}
+			rb.Do(func(item int) {
+				items = append(items, item)
+			})
+			assert.Equal(t, tc.exp, items)
+
+			snapshot := rb.Snapshot()
+			assert.Equal(t, tc.exp, snapshot)
+
+			// The snapshot is a copy
+			if len(snapshot) > 0 {
+				snapshot[0] = -1
+				assert.Equal(t, tc.exp[0], rb.Front())
+			}
+		})
+	}
+}
+
+func TestRingBuffer_Cap(t *testing.T) {
+	var rb *RingBuffer[int]
+	assert.Equal(t, 0, rb.Cap())
+
+	rb = NewRingBuffer[int](3)
+	rb.PushBack(1)
+	assert.Equal(t, 3, rb.Cap())
+}
+
 func TestRingBuffer_Len(t *testing.T) {
 	t.Run("Nil", func(t *testing.T) {
 		// maximum capacity doesn't matter for this test
@@ -121,6 +334,28 @@ func TestRingBuffer_Len(t *testing.T) {
 		rb := NewRingBuffer[int](rand.Intn(32))
 		assert.Equal(t, 0, rb.Len())
 	})
+
+	// Run with -race: Len is read while the buffer is being written.
+	t.Run("Concurrent with writes", func(t *testing.T) {
+		var (
+			rb   = NewRingBuffer[int](8)
+			done = make(chan struct{})
+		)
+		go func() {
+			defer close(done)
+			for i := 0; i < 1000; i++ {
+				rb.PushBack(i)
+				if rb.Len() > 4 {
+					rb.PopFront()
+				}
+			}
+		}()
+
+		for i := 0; i < 1000; i++ {
+			assert.LessOrEqual(t, rb.Len(), 8)
+		}
+		<-done
+	})
 }
 
 func TestRingBuffer_PushPopLen(t *testing.T) {
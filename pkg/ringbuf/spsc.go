@@ -0,0 +1,173 @@
+package ringbuf
+
+import (
+	"context"
+	"runtime"
+	"sync/atomic"
+	"time"
+)
+
+// cacheLineSize is the size of the CPU cache line on most platforms. The
+// indexes of a SPSC are padded to it so that the producer and the consumer
+// do not invalidate each other's cache line (false sharing).
+const cacheLineSize = 64
+
+// spscSpins is how many times Push and Pop yield the processor before they
+// start sleeping while the buffer is full or empty.
+const spscSpins = 64
+
+// spscSleep is how long Push and Pop sleep at a time while the buffer is
+// full or empty, after spinning.
+const spscSleep = 50 * time.Microsecond
+
+// SPSC is a lock-free ring buffer for a single producer goroutine and a
+// single consumer goroutine, e.g. two stages of a pipeline in place of a
+// buffered channel.
+//
+// Unlike RingBuffer, it never overwrites: TryPush fails while it is full.
+// Calling TryPush or Push from more than one goroutine at a time, or TryPop
+// or Pop from more than one goroutine at a time, is a data race.
+type SPSC[T any] struct {
+	_ [cacheLineSize]byte
+
+	// head is the index of the next element to pop. It is written by the
+	// consumer only, and cachedTail is the consumer's copy of tail.
+	head       atomic.Uint64
+	cachedTail uint64
+
+	_ [cacheLineSize - 16]byte
+
+	// tail is the index of the next element to push. It is written by the
+	// producer only, and cachedHead is the producer's copy of head.
+	tail       atomic.Uint64
+	cachedHead uint64
+
+	_ [cacheLineSize - 16]byte
+
+	// The indexes grow unbounded and are mapped into buf by mask, which
+	// needs a power-of-two capacity.
+	mask uint64
+	buf  []T
+}
+
+// NewSPSC creates a new SPSC with a capacity of at least capacity elements,
+// rounded up to a power of two.
+func NewSPSC[T any](capacity int) *SPSC[T] {
+	size := 1
+	for size < capacity {
+		size <<= 1
+	}
+
+	return &SPSC[T]{
+		mask: uint64(size - 1),
+		buf:  make([]T, size),
+	}
+}
+
+// TryPush appends an element to the back of the queue and reports whether
+// there was room for it. It must only be called by the producer.
+func (r *SPSC[T]) TryPush(item T) bool {
+	tail := r.tail.Load()
+	if tail-r.cachedHead == uint64(len(r.buf)) {
+		// The buffer looked full the last time: refresh the copy of the
+		// consumer's index.
+		r.cachedHead = r.head.Load()
+		if tail-r.cachedHead == uint64(len(r.buf)) {
+			return false
+		}
+	}
+
+	r.buf[tail&r.mask] = item
+	// The store publishes the element to the consumer.
+	r.tail.Store(tail + 1)
+
+	return true
+}
+
+// TryPop removes and returns the element from the front of the queue, and
+// reports whether there was one. It must only be called by the consumer.
+func (r *SPSC[T]) TryPop() (T, bool) {
+	var zero T
+
+	head := r.head.Load()
+	if head == r.cachedTail {
+		// The buffer looked empty the last time: refresh the copy of the
+		// producer's index.
+		r.cachedTail = r.tail.Load()
+		if head == r.cachedTail {
+			return zero, false
+		}
+	}
+
+	i := head & r.mask
+	item := r.buf[i]
+	// Do not keep the element alive from the buffer.
+	r.buf[i] = zero
+	// The store hands the slot back to the producer.
+	r.head.Store(head + 1)
+
+	return item, true
+}
+
+// Push appends an element to the back of the queue, waiting for room while
+// it is full. It returns the context's error if it is done first. It must
+// only be called by the producer.
+func (r *SPSC[T]) Push(ctx context.Context, item T) error {
+	for i := 0; !r.TryPush(item); i++ {
+		if err := wait(ctx, i); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// Pop removes and returns the element from the front of the queue, waiting
+// for one while it is empty. It returns the context's error if it is done
+// first. It must only be called by the consumer.
+func (r *SPSC[T]) Pop(ctx context.Context) (T, error) {
+	for i := 0; ; i++ {
+		if item, ok := r.TryPop(); ok {
+			return item, nil
+		}
+		if err := wait(ctx, i); err != nil {
+			var zero T
+			return zero, err
+		}
+	}
+}
+
+// wait backs off the i-th time a SPSC was found full or empty: it yields
+// the processor at first, as the other side is likely running, and sleeps
+// afterwards.
+func wait(ctx context.Context, i int) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	if i < spscSpins {
+		runtime.Gosched()
+	} else {
+		time.Sleep(spscSleep)
+	}
+
+	return nil
+}
+
+// Len returns the number of elements currently stored in the queue. It may
+// be called from any goroutine, but it is then only a snapshot.
+func (r *SPSC[T]) Len() int {
+	head := r.head.Load()
+	tail := r.tail.Load()
+	// head is loaded first, so it cannot be past tail, but both sides may
+	// move in between.
+	if n := int(tail - head); n < len(r.buf) {
+		return n
+	}
+
+	return len(r.buf)
+}
+
+// Cap returns the number of elements the queue can hold.
+func (r *SPSC[T]) Cap() int {
+	return len(r.buf)
+}
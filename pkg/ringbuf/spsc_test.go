@@ -0,0 +1,182 @@
+package ringbuf
+
+import (
+	"context"
+	"runtime"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNewSPSC(t *testing.T) {
+	tests := []struct {
+		capacity int
+		exp      int
+	}{
+		{0, 1},
+		{1, 1},
+		{3, 4},
+		{8, 8},
+		{200, 256},
+	}
+
+	for _, tc := range tests {
+		assert.Equal(t, tc.exp, NewSPSC[int](tc.capacity).Cap())
+	}
+}
+
+func TestSPSC_TryPushTryPop(t *testing.T) {
+	t.Run("FIFO across wraparound", func(t *testing.T) {
+		rb := NewSPSC[int](4)
+
+		for i := 1; i <= 10; i++ {
+			assert.True(t, rb.TryPush(i))
+			assert.True(t, rb.TryPush(i*10))
+			assert.Equal(t, 2, rb.Len())
+
+			item, ok := rb.TryPop()
+			assert.True(t, ok)
+			assert.Equal(t, i, item)
+			item, ok = rb.TryPop()
+			assert.True(t, ok)
+			assert.Equal(t, i*10, item)
+		}
+	})
+
+	t.Run("Full", func(t *testing.T) {
+		rb := NewSPSC[int](2)
+
+		assert.True(t, rb.TryPush(1))
+		assert.True(t, rb.TryPush(2))
+		assert.False(t, rb.TryPush(3))
+		assert.Equal(t, 2, rb.Len())
+
+		rb.TryPop()
+		assert.True(t, rb.TryPush(3))
+	})
+
+	t.Run("Empty", func(t *testing.T) {
+		rb := NewSPSC[int](2)
+
+		item, ok := rb.TryPop()
+		assert.False(t, ok)
+		assert.Equal(t, 0, item)
+		assert.Equal(t, 0, rb.Len())
+	})
+
+	t.Run("Popped elements are released", func(t *testing.T) {
+		rb := NewSPSC[*int](1)
+		rb.TryPush(new(int))
+		rb.TryPop()
+
+		assert.Nil(t, rb.buf[0])
+	})
+}
+
+func TestSPSC_PushPop(t *testing.T) {
+	t.Run("Cancelled while full", func(t *testing.T) {
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+		defer cancel()
+		rb := NewSPSC[int](1)
+		rb.TryPush(1)
+
+		assert.ErrorIs(t, rb.Push(ctx, 2), context.DeadlineExceeded)
+	})
+
+	t.Run("Cancelled while empty", func(t *testing.T) {
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+		defer cancel()
+		rb := NewSPSC[int](1)
+
+		_, err := rb.Pop(ctx)
+		assert.ErrorIs(t, err, context.DeadlineExceeded)
+	})
+
+	// Run with -race: the producer and the consumer share the buffer
+	// without locks.
+	t.Run("Concurrent producer and consumer", func(t *testing.T) {
+		const n = 100_000
+		var (
+			ctx  = context.Background()
+			rb   = NewSPSC[int](8)
+			done = make(chan struct{})
+		)
+
+		go func() {
+			defer close(done)
+			for i := 0; i < n; i++ {
+				if err := rb.Push(ctx, i); err != nil {
+					t.Error(err)
+					return
+				}
+			}
+		}()
+
+		for i := 0; i < n; i++ {
+			item, err := rb.Pop(ctx)
+			assert.Nil(t, err)
+			if item != i {
+				t.Fatalf("Pop() = %d, want %d", item, i)
+			}
+		}
+		<-done
+		assert.Equal(t, 0, rb.Len())
+	})
+}
+
+// The benchmarks below move b.N elements from a producer goroutine to the
+// benchmark goroutine through a buffer of the same size.
+const benchmarkQueueSize = 256
+
+func Benchmark_SPSC(b *testing.B) {
+	var (
+		ctx = context.Background()
+		rb  = NewSPSC[int](benchmarkQueueSize)
+	)
+
+	go func() {
+		for i := 0; i < b.N; i++ {
+			rb.Push(ctx, i)
+		}
+	}()
+
+	for i := 0; i < b.N; i++ {
+		rb.Pop(ctx)
+	}
+}
+
+func Benchmark_MutexRingBuffer(b *testing.B) {
+	rb := NewRingBuffer[int](benchmarkQueueSize)
+
+	go func() {
+		for i := 0; i < b.N; i++ {
+			// RingBuffer overwrites when full, so wait for room.
+			for rb.Len() == benchmarkQueueSize {
+				runtime.Gosched()
+			}
+			rb.PushBack(i)
+		}
+	}()
+
+	for i := 0; i < b.N; i++ {
+		for rb.Len() == 0 {
+			runtime.Gosched()
+		}
+		rb.PopFront()
+	}
+}
+
+func Benchmark_Channel(b *testing.B) {
+	ch := make(chan int, benchmarkQueueSize)
+
+	go func() {
+		for i := 0; i < b.N; i++ {
+			ch <- i
+		}
+	}()
+
+	for i := 0; i < b.N; i++ {
+		<-ch
+	}
+}
@@ -0,0 +1,133 @@
+package ringbuf
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"time"
+)
+
+// ErrOutOfOrder is returned by TimeRingBuffer.Push for elements older than
+// the newest one.
+var ErrOutOfOrder = errors.New("element older than the newest one")
+
+// Timed is an element of a TimeRingBuffer with its timestamp.
+type Timed[T any] struct {
+	Time  time.Time
+	Value T
+}
+
+// TimeRingBuffer is a growable ring buffer of timestamped elements ordered by
+// time, the backbone of time windows: elements are pushed at the back as they
+// come and evicted from the front once they are too old. See EvictBefore.
+type TimeRingBuffer[T any] struct {
+	rb *RingBuffer[Timed[T]]
+}
+
+// NewTimeRingBuffer creates a new TimeRingBuffer with a given initial
+// capacity. It grows like a ring buffer created by NewGrowableRingBuffer with
+// the same options. If it has a maximum capacity, Push evicts the oldest
+// element when it is full.
+func NewTimeRingBuffer[T any](initCap int, opts ...GrowOption) *TimeRingBuffer[T] {
+	return &TimeRingBuffer[T]{
+		rb: NewGrowableRingBuffer[Timed[T]](initCap, opts...),
+	}
+}
+
+// Push appends an element with timestamp t to the back of the queue. It
+// returns ErrOutOfOrder, leaving the queue unchanged, if t is before the
+// timestamp of the last element.
+// If the ring buffer is full at its maximum capacity, the element at the
+// front of the queue is evicted to make room and returned, along with true.
+func (r *TimeRingBuffer[T]) Push(t time.Time, v T) (evicted Timed[T], ok bool, err error) {
+	rb := r.rb
+	rb.mu.Lock()
+	defer rb.mu.Unlock()
+	if rb.count > 0 {
+		if last := rb.buf[(rb.start+rb.count-1)%len(rb.buf)].Time; t.Before(last) {
+			err = fmt.Errorf("%w: %s before %s", ErrOutOfOrder, t.Format(time.RFC3339Nano), last.Format(time.RFC3339Nano))
+			return evicted, false, err
+		}
+	}
+
+	item := Timed[T]{Time: t, Value: v}
+	if rb.full() {
+		evicted, ok = rb.buf[rb.start], true
+		rb.start++
+		rb.start %= len(rb.buf)
+		rb.count--
+	}
+	rb.push(item)
+
+	return evicted, ok, nil
+}
+
+// EvictBefore removes the elements with a timestamp before t from the front
+// of the queue, and returns them from oldest to newest.
+func (r *TimeRingBuffer[T]) EvictBefore(t time.Time) []Timed[T] {
+	rb := r.rb
+	rb.mu.Lock()
+	defer rb.mu.Unlock()
+	n := r.search(t)
+	items := make([]Timed[T], n)
+	rb.read(items)
+	rb.start = (rb.start + n) % max(len(rb.buf), 1)
+	rb.count -= n
+	rb.maybeShrink()
+
+	return items
+}
+
+// Search returns the index, counting from the front, of the first element
+// with a timestamp at or after t, or Len if there is none. It takes
+// O(log n) time.
+func (r *TimeRingBuffer[T]) Search(t time.Time) int {
+	r.rb.mu.Lock()
+	defer r.rb.mu.Unlock()
+
+	return r.search(t)
+}
+
+// search must be called with r.rb.mu held.
+func (r *TimeRingBuffer[T]) search(t time.Time) int {
+	rb := r.rb
+
+	return sort.Search(rb.count, func(i int) bool {
+		return !rb.buf[(rb.start+i)%len(rb.buf)].Time.Before(t)
+	})
+}
+
+// At returns the i-th element of the queue, counting from the front, without
+// removing it.
+// If i is out of range, the call panics
+func (r *TimeRingBuffer[T]) At(i int) Timed[T] {
+	return r.rb.At(i)
+}
+
+// Front returns the oldest element without removing it.
+// If the ring buffer is empty, the call panics
+func (r *TimeRingBuffer[T]) Front() Timed[T] {
+	return r.rb.Front()
+}
+
+// Back returns the newest element without removing it.
+// If the ring buffer is empty, the call panics
+func (r *TimeRingBuffer[T]) Back() Timed[T] {
+	return r.rb.Back()
+}
+
+// PopFront removes and returns the oldest element.
+// If the ring buffer is empty, the call panics
+func (r *TimeRingBuffer[T]) PopFront() Timed[T] {
+	return r.rb.PopFront()
+}
+
+// Snapshot returns a copy of the elements, from oldest to newest.
+func (r *TimeRingBuffer[T]) Snapshot() []Timed[T] {
+	return r.rb.Snapshot()
+}
+
+// Len returns the number of elements currently stored in the queue.
+func (r *TimeRingBuffer[T]) Len() int {
+	return r.rb.Len()
+}
@@ -0,0 +1,108 @@
+package ringbuf
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// at returns the time s seconds after the Unix epoch.
+func at(s int) time.Time {
+	return time.Unix(int64(s), 0)
+}
+
+// push pushes v at s seconds to rb, failing the test if it evicts an
+// element or is out of order.
+func push[T any](t *testing.T, rb *TimeRingBuffer[T], s int, v T) {
+	t.Helper()
+
+	_, ok, err := rb.Push(at(s), v)
+	assert.NoError(t, err)
+	assert.False(t, ok)
+}
+
+func TestTimeRingBuffer_Push(t *testing.T) {
+	rb := NewTimeRingBuffer[string](1)
+
+	push(t, rb, 1, "a")
+	push(t, rb, 2, "b")
+	// Equal timestamps are in order
+	push(t, rb, 2, "c")
+	_, ok, err := rb.Push(at(1), "d")
+	assert.ErrorIs(t, err, ErrOutOfOrder)
+	assert.False(t, ok)
+
+	assert.Equal(t, []Timed[string]{{at(1), "a"}, {at(2), "b"}, {at(2), "c"}}, rb.Snapshot())
+	assert.Equal(t, Timed[string]{at(1), "a"}, rb.Front())
+	assert.Equal(t, Timed[string]{at(2), "c"}, rb.Back())
+
+	t.Run("Maximum capacity", func(t *testing.T) {
+		rb := NewTimeRingBuffer[string](1, WithMaxCap(2))
+		push(t, rb, 0, "a")
+		push(t, rb, 1, "b")
+
+		evicted, ok, err := rb.Push(at(2), "c")
+		assert.NoError(t, err)
+		assert.True(t, ok)
+		assert.Equal(t, Timed[string]{at(0), "a"}, evicted)
+		assert.Equal(t, []Timed[string]{{at(1), "b"}, {at(2), "c"}}, rb.Snapshot())
+	})
+}
+
+func TestTimeRingBuffer_Search(t *testing.T) {
+	rb := NewTimeRingBuffer[int](4)
+	// Wrap around the end of the buffer
+	for _, s := range []int{0, 0, 10, 20} {
+		push(t, rb, s, s)
+	}
+	rb.PopFront()
+	rb.PopFront()
+	for _, s := range []int{20, 30} {
+		push(t, rb, s, s)
+	}
+
+	tests := []struct {
+		t   int
+		exp int
+	}{
+		{5, 0},
+		{10, 0},
+		{15, 1},
+		{20, 1},
+		{25, 3},
+		{30, 3},
+		{35, 4},
+	}
+
+	for _, tc := range tests {
+		assert.Equal(t, tc.exp, rb.Search(at(tc.t)), "Search(%d)", tc.t)
+	}
+
+	t.Run("Empty", func(t *testing.T) {
+		assert.Equal(t, 0, NewTimeRingBuffer[int](0).Search(at(1)))
+	})
+}
+
+func TestTimeRingBuffer_EvictBefore(t *testing.T) {
+	rb := NewTimeRingBuffer[int](2, WithShrink())
+	for s := 0; s < 8; s++ {
+		push(t, rb, s, s)
+	}
+
+	assert.Equal(t, []Timed[int]{}, rb.EvictBefore(at(0)))
+	assert.Equal(t, []Timed[int]{{at(0), 0}, {at(1), 1}, {at(2), 2}}, rb.EvictBefore(at(3)))
+	assert.Equal(t, 5, rb.Len())
+	assert.Equal(t, Timed[int]{at(3), 3}, rb.At(0))
+
+	evicted := rb.EvictBefore(at(100))
+	assert.Len(t, evicted, 5)
+	assert.Equal(t, 7, evicted[4].Value)
+	assert.Equal(t, 0, rb.Len())
+	// Shrunk as it emptied
+	assert.Less(t, rb.rb.Cap(), 8)
+
+	// Still usable
+	push(t, rb, 100, 100)
+	assert.Equal(t, Timed[int]{at(100), 100}, rb.PopFront())
+}
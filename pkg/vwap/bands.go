@@ -0,0 +1,53 @@
+package vwap
+
+import (
+	"math/big"
+)
+
+// Band is a pair of prices at k volume-weighted standard deviations below
+// and above the VWAP.
+type Band struct {
+	K     float64
+	Lower *big.Float
+	Upper *big.Float
+}
+
+// stdDev returns the volume-weighted standard deviation of the prices in
+// the window:
+//
+//	σ² = Σ(q·p²)/Σq - VWAP²
+//
+// It must be called with c.mu held and bands enabled.
+func (c *Calculator) stdDev() *big.Float {
+	v := new(big.Float).SetPrec(prec).SetMode(mode)
+	if c.cumulativeVolume.Sign() == 0 {
+		return v
+	}
+
+	v.Quo(c.cumulativeSquaredTypicalPrice, c.cumulativeVolume)
+	v.Sub(v, new(big.Float).SetPrec(prec).SetMode(mode).Mul(c.vwap, c.vwap))
+	// Rounding errors of the rolling sums may push a (near) zero variance
+	// below zero, which Sqrt does not accept.
+	if v.Sign() < 0 {
+		v.SetInt64(0)
+	}
+
+	return v.Sqrt(v)
+}
+
+// bandsAround returns the configured bands around the current VWAP for a
+// standard deviation sd. It must be called with c.mu held.
+func (c *Calculator) bandsAround(sd *big.Float) []Band {
+	bands := make([]Band, 0, len(c.bands))
+	for _, k := range c.bands {
+		d := new(big.Float).SetPrec(prec).SetMode(mode).SetFloat64(k)
+		d.Mul(d, sd)
+		bands = append(bands, Band{
+			K:     k,
+			Lower: new(big.Float).SetPrec(prec).SetMode(mode).Sub(c.vwap, d),
+			Upper: new(big.Float).SetPrec(prec).SetMode(mode).Add(c.vwap, d),
+		})
+	}
+
+	return bands
+}
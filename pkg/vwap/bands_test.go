@@ -0,0 +1,71 @@
+package vwap
+
+import (
+	"math"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestWithBands(t *testing.T) {
+	t.Run("Invalid multipliers", func(t *testing.T) {
+		for _, k := range []float64{0, -1, math.Inf(1), math.NaN()} {
+			_, err := NewCalculator(1, WithBands(k))
+
+			assert.ErrorIs(t, err, ErrInvalidBand)
+		}
+	})
+
+	t.Run("Disabled", func(t *testing.T) {
+		calc, _ := NewCalculator(3)
+
+		r, _ := calc.Update(Trade{Price: "1", Size: "1"})
+
+		assert.Nil(t, r.StdDev)
+		assert.Nil(t, r.Bands)
+	})
+
+	t.Run("Single trade has no deviation", func(t *testing.T) {
+		calc, _ := NewCalculator(3, WithBands(1))
+
+		r, _ := calc.Update(Trade{Price: "1.1", Size: "0.3"})
+
+		assert.Equal(t, 0, r.StdDev.Sign())
+		assert.Equal(t, 0, r.Bands[0].Lower.Cmp(r.VWAP))
+		assert.Equal(t, 0, r.Bands[0].Upper.Cmp(r.VWAP))
+	})
+
+	t.Run("Bands around VWAP", func(t *testing.T) {
+		calc, _ := NewCalculator(3, WithBands(1, 2))
+
+		calc.Update(Trade{Price: "1", Size: "1"})
+		calc.Update(Trade{Price: "2", Size: "1"})
+		r, _ := calc.Update(Trade{Price: "3", Size: "1"})
+
+		// σ² = (1 + 4 + 9)/3 - 2² = 2/3
+		sd := math.Sqrt(2.0 / 3.0)
+		act, _ := r.StdDev.Float64()
+		assert.InDelta(t, sd, act, 1e-12)
+
+		assert.Len(t, r.Bands, 2)
+		for i, k := range []float64{1, 2} {
+			lower, _ := r.Bands[i].Lower.Float64()
+			upper, _ := r.Bands[i].Upper.Float64()
+			assert.Equal(t, k, r.Bands[i].K)
+			assert.InDelta(t, 2-k*sd, lower, 1e-12)
+			assert.InDelta(t, 2+k*sd, upper, 1e-12)
+		}
+	})
+
+	t.Run("Sliding window evicts squares", func(t *testing.T) {
+		calc, _ := NewCalculator(2, WithBands(1))
+
+		calc.Update(Trade{Price: "100", Size: "5"})
+		calc.Update(Trade{Price: "2", Size: "1"})
+		r, _ := calc.Update(Trade{Price: "4", Size: "3"})
+
+		// VWAP = 14/4, σ² = (4 + 48)/4 - 3.5² = 0.75
+		act, _ := r.StdDev.Float64()
+		assert.InDelta(t, math.Sqrt(0.75), act, 1e-12)
+	})
+}
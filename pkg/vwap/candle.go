@@ -0,0 +1,164 @@
+package vwap
+
+import (
+	"fmt"
+	"math/big"
+	"sync"
+	"time"
+)
+
+// Candle is an OHLCV bar of the trades within an interval.
+type Candle struct {
+	// Start is the start of the interval, a multiple of the interval since
+	// the zero time. The interval is half-open: [Start, Start+Interval).
+	Start    time.Time
+	Interval time.Duration
+
+	Open   *big.Float
+	High   *big.Float
+	Low    *big.Float
+	Close  *big.Float
+	Volume *big.Float
+	// VWAP is the volume-weighted average price of the trades in the bar.
+	VWAP  *big.Float
+	Count int
+}
+
+// End returns the end of the candle's interval, exclusive.
+func (c Candle) End() time.Time {
+	return c.Start.Add(c.Interval)
+}
+
+// CandleBuilder aggregates a stream of trades into Candles.
+//
+// Candles are driven by trade time: a candle is closed when a trade of a
+// later interval is received, or by Flush once its interval has ended. Since
+// the stream may not be ordered, trades of an already closed interval are
+// added to the next candle. Intervals without trades produce no candle.
+type CandleBuilder struct {
+	mu sync.Mutex
+
+	interval time.Duration
+
+	// current is the candle being built, nil if no trade was received since
+	// the last candle was closed.
+	current *Candle
+
+	// cumulativeTypicalPrice is the summation of all prices multiplied by
+	// the quantity of the trades in the current candle.
+	cumulativeTypicalPrice *big.Float
+
+	// closedUntil is the end of the last closed candle.
+	closedUntil time.Time
+}
+
+// NewCandleBuilder creates a CandleBuilder for candles of the given interval.
+// It returns ErrInvalidWindow if the interval is not positive.
+func NewCandleBuilder(interval time.Duration) (*CandleBuilder, error) {
+	if interval <= 0 {
+		return nil, fmt.Errorf("%w: interval %s", ErrInvalidWindow, interval)
+	}
+
+	return &CandleBuilder{
+		interval:               interval,
+		cumulativeTypicalPrice: new(big.Float).SetPrec(prec).SetMode(mode),
+	}, nil
+}
+
+// Update adds a trade to the current candle. If the trade belongs to a later
+// interval, the current candle is closed and returned, and the trade starts
+// a new candle.
+//
+// Trades are validated like in Calculator.Update.
+func (b *CandleBuilder) Update(t Trade) (*Candle, error) {
+	p, err := parse(t.Price, ErrNonPositivePrice)
+	if err != nil {
+		return nil, err
+	}
+	q, err := parse(t.Size, ErrNonPositiveSize)
+	if err != nil {
+		return nil, err
+	}
+	pq := new(big.Float).SetPrec(prec).SetMode(mode).Mul(p, q)
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	var closed *Candle
+	if b.current != nil && !t.Time.Before(b.current.End()) {
+		closed = b.close()
+	}
+
+	if b.current == nil {
+		start := t.Time.Truncate(b.interval)
+		if start.Before(b.closedUntil) {
+			start = b.closedUntil
+		}
+		b.current = &Candle{
+			Start:    start,
+			Interval: b.interval,
+			Open:     p,
+			High:     new(big.Float).Set(p),
+			Low:      new(big.Float).Set(p),
+			Close:    new(big.Float).Set(p),
+			Volume:   new(big.Float).SetPrec(prec).SetMode(mode),
+		}
+	}
+
+	c := b.current
+	if p.Cmp(c.High) > 0 {
+		c.High.Set(p)
+	}
+	if p.Cmp(c.Low) < 0 {
+		c.Low.Set(p)
+	}
+	c.Close.Set(p)
+	c.Volume.Add(c.Volume, q)
+	c.Count++
+	b.cumulativeTypicalPrice.Add(b.cumulativeTypicalPrice, pq)
+
+	return closed, nil
+}
+
+// Flush closes and returns the current candle if its interval ended before
+// now. It returns nil otherwise.
+//
+// It is meant to be called periodically so that candles of products with no
+// trades in the following interval are not held back indefinitely.
+func (b *CandleBuilder) Flush(now time.Time) *Candle {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if b.current == nil || now.Before(b.current.End()) {
+		return nil
+	}
+
+	return b.close()
+}
+
+// Drain closes and returns the current candle, even if its interval has not
+// ended yet. It returns nil if there is no current candle.
+//
+// It is meant to be called on shutdown so that the last candle is not lost.
+func (b *CandleBuilder) Drain() *Candle {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if b.current == nil {
+		return nil
+	}
+
+	return b.close()
+}
+
+// close must be called with b.mu held and a current candle.
+func (b *CandleBuilder) close() *Candle {
+	c := b.current
+	c.VWAP = new(big.Float).SetPrec(prec).SetMode(mode).Quo(b.cumulativeTypicalPrice, c.Volume)
+
+	b.current = nil
+	b.cumulativeTypicalPrice.SetInt64(0)
+	b.closedUntil = c.End()
+
+	return c
+}
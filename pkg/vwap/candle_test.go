@@ -0,0 +1,103 @@
+package vwap
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNewCandleBuilder(t *testing.T) {
+	for _, d := range []time.Duration{0, -time.Minute} {
+		_, err := NewCandleBuilder(d)
+
+		assert.ErrorIs(t, err, ErrInvalidWindow)
+	}
+}
+
+func TestCandleBuilder_Update(t *testing.T) {
+	t0 := time.Date(2022, 9, 12, 14, 28, 0, 0, time.UTC)
+
+	t.Run("Invalid trade", func(t *testing.T) {
+		b, _ := NewCandleBuilder(time.Minute)
+
+		_, err := b.Update(Trade{Price: "-1", Size: "1", Time: t0})
+
+		assert.ErrorIs(t, err, ErrNonPositivePrice)
+		assert.Nil(t, b.Flush(t0.Add(time.Hour)))
+	})
+
+	t.Run("Closes on interval boundaries", func(t *testing.T) {
+		b, _ := NewCandleBuilder(time.Minute)
+
+		trades := []Trade{
+			{Price: "3", Size: "1", Time: t0.Add(10 * time.Second)},
+			{Price: "5", Size: "2", Time: t0.Add(20 * time.Second)},
+			{Price: "1", Size: "1", Time: t0.Add(30 * time.Second)},
+			{Price: "2", Size: "4", Time: t0.Add(59 * time.Second)},
+		}
+		for _, trade := range trades {
+			c, err := b.Update(trade)
+			assert.Nil(t, err)
+			assert.Nil(t, c)
+		}
+
+		c, _ := b.Update(Trade{Price: "4", Size: "1", Time: t0.Add(time.Minute)})
+
+		assert.Equal(t, t0, c.Start)
+		assert.Equal(t, t0.Add(time.Minute), c.End())
+		assert.Equal(t, "3", c.Open.String())
+		assert.Equal(t, "5", c.High.String())
+		assert.Equal(t, "1", c.Low.String())
+		assert.Equal(t, "2", c.Close.String())
+		assert.Equal(t, "8", c.Volume.String())
+		// (3 + 10 + 1 + 8) / 8
+		assert.Equal(t, "2.75", c.VWAP.String())
+		assert.Equal(t, 4, c.Count)
+
+		// The trade that closed the candle opened the next one
+		c, _ = b.Update(Trade{Price: "6", Size: "1", Time: t0.Add(3 * time.Minute)})
+		assert.Equal(t, t0.Add(time.Minute), c.Start)
+		assert.Equal(t, "4", c.Open.String())
+		assert.Equal(t, "4", c.Close.String())
+		assert.Equal(t, 1, c.Count)
+	})
+
+	t.Run("Flush closes quiet candles", func(t *testing.T) {
+		b, _ := NewCandleBuilder(time.Minute)
+
+		b.Update(Trade{Price: "3", Size: "1", Time: t0.Add(10 * time.Second)})
+
+		assert.Nil(t, b.Flush(t0.Add(59*time.Second)))
+		c := b.Flush(t0.Add(time.Minute))
+		assert.Equal(t, t0, c.Start)
+		assert.Equal(t, "3", c.VWAP.String())
+		assert.Nil(t, b.Flush(t0.Add(time.Hour)))
+	})
+
+	t.Run("Drain closes the current candle", func(t *testing.T) {
+		b, _ := NewCandleBuilder(time.Minute)
+		assert.Nil(t, b.Drain())
+
+		b.Update(Trade{Price: "3", Size: "1", Time: t0.Add(10 * time.Second)})
+		b.Update(Trade{Price: "5", Size: "1", Time: t0.Add(20 * time.Second)})
+
+		c := b.Drain()
+		assert.Equal(t, t0, c.Start)
+		assert.Equal(t, "4", c.VWAP.String())
+		assert.Equal(t, 2, c.Count)
+		assert.Nil(t, b.Drain())
+	})
+
+	t.Run("Late trades are added to the next candle", func(t *testing.T) {
+		b, _ := NewCandleBuilder(time.Minute)
+
+		b.Update(Trade{Price: "3", Size: "1", Time: t0.Add(10 * time.Second)})
+		b.Flush(t0.Add(time.Minute))
+		b.Update(Trade{Price: "4", Size: "1", Time: t0.Add(50 * time.Second)})
+
+		c := b.Flush(t0.Add(2 * time.Minute))
+		assert.Equal(t, t0.Add(time.Minute), c.Start)
+		assert.Equal(t, "4", c.Open.String())
+	})
+}
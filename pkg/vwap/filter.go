@@ -0,0 +1,338 @@
+package vwap
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"math/big"
+	"sync"
+
+	"github.com/felipeblassioli/vwap/pkg/ringbuf"
+)
+
+// ErrInvalidDeviation is returned by NewOutlierFilter for maximum deviations
+// that are not positive and finite, or when none is configured.
+var ErrInvalidDeviation = errors.New("maximum deviation must be positive and finite")
+
+// Reference is the price an OutlierFilter compares trades against.
+type Reference uint8
+
+const (
+	// ReferenceVWAP compares trades against the VWAP of the accepted trades
+	// in the filter's window.
+	ReferenceVWAP Reference = iota
+	// ReferenceMedian compares trades against the volume-weighted median
+	// price of the accepted trades in the filter's window, which a burst of
+	// outliers moves less than the VWAP.
+	ReferenceMedian
+)
+
+// Rejection is a trade rejected by an OutlierFilter.
+type Rejection struct {
+	Trade Trade
+	// Reference is the price the trade was compared against.
+	Reference *big.Float
+	// Deviation is the distance between the trade's price and Reference, in
+	// percent of Reference.
+	Deviation *big.Float
+	// StdDevs is the distance between the trade's price and Reference, in
+	// volume-weighted standard deviations of the window. It is nil unless
+	// the filter was created WithMaxStdDevs and the window is not flat.
+	StdDevs *big.Float
+}
+
+func (r Rejection) String() string {
+	s := fmt.Sprintf(
+		"price=%s size=%s reference=%s deviation=%.2f%%",
+		r.Trade.Price, r.Trade.Size, r.Reference.Text('f', numPrecDigits), r.Deviation,
+	)
+	if r.StdDevs != nil {
+		s += fmt.Sprintf(" (%.2fσ)", r.StdDevs)
+	}
+
+	return s
+}
+
+// FilterStats counts the trades checked by an OutlierFilter.
+type FilterStats struct {
+	Accepted int
+	Rejected int
+}
+
+// FilterOption configures optional behaviour of an OutlierFilter.
+type FilterOption func(*OutlierFilter) error
+
+// WithMaxDeviation rejects trades whose price deviates from the reference
+// price by more than pct percent of it.
+func WithMaxDeviation(pct float64) FilterOption {
+	return func(f *OutlierFilter) error {
+		if pct <= 0 || math.IsInf(pct, 0) || math.IsNaN(pct) {
+			return fmt.Errorf("%w: %v%%", ErrInvalidDeviation, pct)
+		}
+		f.maxDeviation = new(big.Float).SetPrec(prec).SetMode(mode).SetFloat64(pct)
+
+		return nil
+	}
+}
+
+// WithMaxStdDevs rejects trades whose price deviates from the reference
+// price by more than k volume-weighted standard deviations of the prices in
+// the window.
+//
+// While all prices in the window are equal, the standard deviation is zero
+// and only WithMaxDeviation applies.
+func WithMaxStdDevs(k float64) FilterOption {
+	return func(f *OutlierFilter) error {
+		if k <= 0 || math.IsInf(k, 0) || math.IsNaN(k) {
+			return fmt.Errorf("%w: %vσ", ErrInvalidDeviation, k)
+		}
+		f.maxStdDevs = new(big.Float).SetPrec(prec).SetMode(mode).SetFloat64(k)
+
+		return nil
+	}
+}
+
+// WithMedianReference compares trades against the volume-weighted median
+// price of the window instead of its VWAP. See ReferenceMedian.
+func WithMedianReference() FilterOption {
+	return func(f *OutlierFilter) error {
+		f.reference = ReferenceMedian
+
+		return nil
+	}
+}
+
+// WithMinTrades sets how many trades the window must hold before trades are
+// checked at all; until then every trade is accepted. It defaults to the
+// window width.
+func WithMinTrades(n int) FilterOption {
+	return func(f *OutlierFilter) error {
+		if n <= 0 || n > f.windowWidth {
+			return fmt.Errorf("%w: minimum of %d trades in a window of %d", ErrInvalidWindow, n, f.windowWidth)
+		}
+		f.minTrades = n
+
+		return nil
+	}
+}
+
+// WithQuarantine keeps the last n rejected trades for inspection. See
+// OutlierFilter.Quarantined.
+func WithQuarantine(n int) FilterOption {
+	return func(f *OutlierFilter) error {
+		if n <= 0 {
+			return fmt.Errorf("%w: quarantine of %d trades", ErrInvalidWindow, n)
+		}
+		f.quarantine = ringbuf.NewRingBuffer[Rejection](n)
+
+		return nil
+	}
+}
+
+// WithResetAfter makes the filter assume the market moved, rather than
+// keep rejecting it, after n consecutive rejections: the window is emptied
+// and the n-th rejected trade is accepted instead.
+//
+// Without it, a lasting price move past the maximum deviation is rejected
+// for good.
+func WithResetAfter(n int) FilterOption {
+	return func(f *OutlierFilter) error {
+		if n <= 0 {
+			return fmt.Errorf("%w: reset after %d rejections", ErrInvalidWindow, n)
+		}
+		f.resetAfter = n
+
+		return nil
+	}
+}
+
+// OutlierFilter rejects trades whose price deviates too much from the
+// recent market, such as fat-finger prints or bad data, before they reach a
+// Calculator.
+//
+// The market is the sliding window of the last accepted trades, whose VWAP
+// or volume-weighted median is the reference price.
+type OutlierFilter struct {
+	mu sync.Mutex
+
+	// windowWidth is the maximum number of accepted trades the reference
+	// price is calculated from.
+	windowWidth int
+
+	// calc holds the window of accepted trades.
+	calc *Calculator
+
+	reference Reference
+
+	// maxDeviation and maxStdDevs are the configured thresholds, nil when
+	// unset. See WithMaxDeviation and WithMaxStdDevs.
+	maxDeviation *big.Float
+	maxStdDevs   *big.Float
+
+	minTrades int
+
+	// resetAfter is the number of consecutive rejections that empties the
+	// window, and consecutive the current count. See WithResetAfter.
+	resetAfter  int
+	consecutive int
+
+	// quarantine holds the last rejected trades. It is nil unless
+	// WithQuarantine is used.
+	quarantine *ringbuf.RingBuffer[Rejection]
+
+	stats FilterStats
+}
+
+// NewOutlierFilter returns an OutlierFilter over a window of the last
+// windowWidth accepted trades. At least one of WithMaxDeviation and
+// WithMaxStdDevs must be given.
+func NewOutlierFilter(windowWidth int, opts ...FilterOption) (*OutlierFilter, error) {
+	if windowWidth <= 0 {
+		return nil, fmt.Errorf("%w: %d", ErrInvalidWindow, windowWidth)
+	}
+
+	f := &OutlierFilter{
+		windowWidth: windowWidth,
+		minTrades:   windowWidth,
+	}
+	for _, opt := range opts {
+		if err := opt(f); err != nil {
+			return nil, err
+		}
+	}
+	if f.maxDeviation == nil && f.maxStdDevs == nil {
+		return nil, fmt.Errorf("%w: none configured", ErrInvalidDeviation)
+	}
+
+	if err := f.resetWindow(); err != nil {
+		return nil, err
+	}
+
+	return f, nil
+}
+
+// resetWindow empties the window of accepted trades.
+func (f *OutlierFilter) resetWindow() error {
+	var opts []Option
+	if f.maxStdDevs != nil {
+		// The band itself is not used, only the standard deviation.
+		opts = append(opts, WithBands(1))
+	}
+	if f.reference == ReferenceMedian {
+		opts = append(opts, WithPercentiles(50)) //nolint:gomnd // Median
+	}
+
+	calc, err := NewCalculator(f.windowWidth, opts...)
+	if err != nil {
+		return err
+	}
+	f.calc = calc
+
+	return nil
+}
+
+// Check accepts or rejects a trade. Accepted trades join the window and
+// nil is returned; rejected trades are counted, quarantined if enabled, and
+// returned as a Rejection.
+//
+// Trades that cannot take part in a VWAP calculation are neither accepted
+// nor rejected and return the same errors as Calculator.Update.
+func (f *OutlierFilter) Check(t Trade) (*Rejection, error) {
+	p, err := parse(t.Price, ErrNonPositivePrice)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := parse(t.Size, ErrNonPositiveSize); err != nil {
+		return nil, err
+	}
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if r := f.check(t, p); r != nil {
+		f.consecutive++
+		if f.resetAfter == 0 || f.consecutive < f.resetAfter {
+			f.stats.Rejected++
+			if f.quarantine != nil {
+				// The oldest rejected trade is discarded when full.
+				f.quarantine.PushBack(*r)
+			}
+			return r, nil
+		}
+		if err := f.resetWindow(); err != nil {
+			return nil, err
+		}
+	}
+
+	if _, err := f.calc.Update(t); err != nil {
+		return nil, err
+	}
+	f.consecutive = 0
+	f.stats.Accepted++
+
+	return nil, nil
+}
+
+// check returns the Rejection of a trade at price p, or nil if it is within
+// the configured thresholds. It must be called with f.mu held.
+func (f *OutlierFilter) check(t Trade, p *big.Float) *Rejection {
+	window := f.calc.Value()
+	if window.Count < f.minTrades {
+		return nil
+	}
+
+	ref := window.VWAP
+	if f.reference == ReferenceMedian {
+		ref = window.Percentiles[0].Price
+	}
+
+	var (
+		r = Rejection{
+			Trade:     t,
+			Reference: ref,
+		}
+		d        = new(big.Float).SetPrec(prec).SetMode(mode).Sub(p, ref)
+		rejected bool
+	)
+	d.Abs(d)
+
+	//nolint:gomnd // Percent
+	r.Deviation = new(big.Float).SetPrec(prec).SetMode(mode).Quo(d, ref)
+	r.Deviation.Mul(r.Deviation, big.NewFloat(100))
+	if f.maxDeviation != nil && r.Deviation.Cmp(f.maxDeviation) > 0 {
+		rejected = true
+	}
+
+	if f.maxStdDevs != nil && window.StdDev.Sign() > 0 {
+		r.StdDevs = new(big.Float).SetPrec(prec).SetMode(mode).Quo(d, window.StdDev)
+		if r.StdDevs.Cmp(f.maxStdDevs) > 0 {
+			rejected = true
+		}
+	}
+
+	if !rejected {
+		return nil
+	}
+
+	return &r
+}
+
+// Stats returns how many trades were accepted and rejected so far.
+func (f *OutlierFilter) Stats() FilterStats {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	return f.stats
+}
+
+// Quarantined returns the last rejected trades, oldest first, if the filter
+// was created WithQuarantine.
+func (f *OutlierFilter) Quarantined() []Rejection {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if f.quarantine == nil {
+		return nil
+	}
+	return f.quarantine.Snapshot()
+}
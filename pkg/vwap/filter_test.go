@@ -0,0 +1,141 @@
+package vwap
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNewOutlierFilter(t *testing.T) {
+	invalid := []struct {
+		name        string
+		windowWidth int
+		opts        []FilterOption
+		err         error
+	}{
+		{"Zero window", 0, []FilterOption{WithMaxDeviation(1)}, ErrInvalidWindow},
+		{"No threshold", 1, nil, ErrInvalidDeviation},
+		{"Negative deviation", 1, []FilterOption{WithMaxDeviation(-1)}, ErrInvalidDeviation},
+		{"Zero standard deviations", 1, []FilterOption{WithMaxStdDevs(0)}, ErrInvalidDeviation},
+		{"Minimum over window", 1, []FilterOption{WithMaxDeviation(1), WithMinTrades(2)}, ErrInvalidWindow},
+		{"Empty quarantine", 1, []FilterOption{WithMaxDeviation(1), WithQuarantine(0)}, ErrInvalidWindow},
+	}
+	for _, tt := range invalid {
+		t.Run(tt.name, func(t *testing.T) {
+			f, err := NewOutlierFilter(tt.windowWidth, tt.opts...)
+
+			assert.Nil(t, f)
+			assert.ErrorIs(t, err, tt.err)
+		})
+	}
+}
+
+func TestOutlierFilter_Check(t *testing.T) {
+	check := func(t *testing.T, f *OutlierFilter, price, size string) *Rejection {
+		t.Helper()
+		r, err := f.Check(Trade{Price: price, Size: size})
+		assert.Nil(t, err)
+		return r
+	}
+
+	t.Run("Accepts every trade until the window is full", func(t *testing.T) {
+		f, _ := NewOutlierFilter(3, WithMaxDeviation(10))
+
+		assert.Nil(t, check(t, f, "100", "1"))
+		assert.Nil(t, check(t, f, "1000", "1"))
+		assert.Equal(t, FilterStats{Accepted: 2}, f.Stats())
+	})
+
+	t.Run("Maximum deviation from the VWAP", func(t *testing.T) {
+		f, _ := NewOutlierFilter(3, WithMaxDeviation(10))
+		check(t, f, "100", "1")
+		check(t, f, "100", "1")
+		check(t, f, "100", "1")
+
+		assert.Nil(t, check(t, f, "109", "1"))
+
+		// VWAP of 100, 100 and 109
+		r := check(t, f, "80", "1")
+		assert.Equal(t, "103", r.Reference.String())
+		assert.Equal(t, "22.33", r.Deviation.Text('f', 2))
+		assert.Nil(t, r.StdDevs)
+		assert.Equal(t, "price=80 size=1 reference=103.0000000000000000 deviation=22.33%", r.String())
+
+		assert.Equal(t, FilterStats{Accepted: 4, Rejected: 1}, f.Stats())
+		assert.Equal(t, "103", f.calc.Value().VWAP.String())
+	})
+
+	t.Run("Maximum standard deviations", func(t *testing.T) {
+		f, _ := NewOutlierFilter(4, WithMaxStdDevs(2), WithMinTrades(2))
+		check(t, f, "99", "1")
+		check(t, f, "101", "1")
+
+		// VWAP 100, σ 1
+		r := check(t, f, "102.5", "1")
+		assert.Equal(t, "2.5", r.StdDevs.String())
+		assert.Equal(t, "100", r.Reference.String())
+
+		assert.Nil(t, check(t, f, "101.5", "1"))
+	})
+
+	t.Run("Flat window skips standard deviations", func(t *testing.T) {
+		f, _ := NewOutlierFilter(2, WithMaxStdDevs(2))
+		check(t, f, "100", "1")
+		check(t, f, "100", "1")
+
+		assert.Nil(t, check(t, f, "101", "1"))
+	})
+
+	t.Run("Median reference", func(t *testing.T) {
+		var (
+			vwap, _   = NewOutlierFilter(4, WithMaxDeviation(10))
+			median, _ = NewOutlierFilter(4, WithMaxDeviation(10), WithMedianReference())
+		)
+		for _, f := range []*OutlierFilter{vwap, median} {
+			check(t, f, "100", "1")
+			check(t, f, "100", "1")
+			check(t, f, "100", "1")
+			check(t, f, "130", "2")
+		}
+
+		// VWAP 112
+		assert.Nil(t, check(t, vwap, "115", "1"))
+
+		r := check(t, median, "115", "1")
+		assert.Equal(t, "100", r.Reference.String())
+		assert.Equal(t, "15", r.Deviation.String())
+	})
+
+	t.Run("Quarantine and reset", func(t *testing.T) {
+		f, _ := NewOutlierFilter(2, WithMaxDeviation(10), WithQuarantine(2), WithResetAfter(4))
+		check(t, f, "100", "1")
+		check(t, f, "100", "1")
+
+		assert.NotNil(t, check(t, f, "200", "1"))
+		assert.NotNil(t, check(t, f, "201", "1"))
+		assert.NotNil(t, check(t, f, "202", "1"))
+		assert.Nil(t, check(t, f, "203", "1"))
+
+		q := f.Quarantined()
+		assert.Len(t, q, 2)
+		assert.Equal(t, "201", q[0].Trade.Price)
+		assert.Equal(t, "202", q[1].Trade.Price)
+		assert.Equal(t, FilterStats{Accepted: 3, Rejected: 3}, f.Stats())
+
+		// The window restarted at the new price
+		assert.Equal(t, "203", f.calc.Value().VWAP.String())
+		assert.Nil(t, check(t, f, "204", "1"))
+		assert.NotNil(t, check(t, f, "100", "1"))
+	})
+
+	t.Run("Invalid trade", func(t *testing.T) {
+		f, _ := NewOutlierFilter(1, WithMaxDeviation(10))
+
+		r, err := f.Check(Trade{Price: "1", Size: "0"})
+
+		assert.Nil(t, r)
+		assert.ErrorIs(t, err, ErrNonPositiveSize)
+		assert.Equal(t, FilterStats{}, f.Stats())
+		assert.Nil(t, f.Quarantined())
+	})
+}
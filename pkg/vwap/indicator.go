@@ -0,0 +1,306 @@
+package vwap
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"math/big"
+	"sync"
+	"time"
+
+	"github.com/felipeblassioli/vwap/pkg/ringbuf"
+)
+
+// Indicator is a technical indicator fed by a stream of trades.
+type Indicator interface {
+	// Name identifies the indicator, e.g. "vwap".
+	Name() string
+	// Update feeds a trade to the indicator and returns its new value.
+	Update(t Trade) (*big.Float, error)
+}
+
+// Value is the value of a named Indicator.
+type Value struct {
+	Name  string
+	Value *big.Float
+}
+
+// Indicators is a set of indicators fed by the same trades.
+type Indicators []Indicator
+
+// Update feeds a trade to every indicator and returns their new values, in
+// order.
+//
+// The trade is validated for every indicator of this package before any of
+// them is updated, so an invalid trade leaves them all untouched. Indicators
+// of other packages are only validated by their own Update. Indicators
+// sharing a Calculator, see Calculator.VWAPIndicator, update it once and
+// report values of the same Result.
+func (is Indicators) Update(t Trade) ([]Value, error) {
+	// pts holds the trade as parsed by every shared Calculator.
+	pts := make(map[*Calculator]parsedTrade)
+	for _, i := range is {
+		var err error
+		switch i := i.(type) {
+		case *windowIndicator:
+			if _, ok := pts[i.calc]; !ok {
+				pts[i.calc], err = i.calc.parseTrade(t)
+			}
+		case *TWAP, *EWVWAP:
+			_, _, err = parsePriceSize(t)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", i.Name(), err)
+		}
+	}
+
+	vs := make([]Value, 0, len(is))
+	// results holds the Result of every shared Calculator updated so far.
+	results := make(map[*Calculator]Result, len(pts))
+	for _, i := range is {
+		w, ok := i.(*windowIndicator)
+		if !ok {
+			v, err := i.Update(t)
+			if err != nil {
+				return nil, fmt.Errorf("%s: %w", i.Name(), err)
+			}
+			vs = append(vs, Value{Name: i.Name(), Value: v})
+			continue
+		}
+
+		r, ok := results[w.calc]
+		if !ok {
+			r = w.calc.update(pts[w.calc])
+			results[w.calc] = r
+		}
+		// Values are owned by the caller, even of the same Result.
+		vs = append(vs, Value{Name: i.Name(), Value: new(big.Float).Set(w.value(r))})
+	}
+
+	return vs, nil
+}
+
+// parsePriceSize parses the price and size of a trade, rejecting them like
+// Calculator.Update.
+func parsePriceSize(t Trade) (p, q *big.Float, err error) {
+	if p, err = parse(t.Price, ErrNonPositivePrice); err != nil {
+		return nil, nil, err
+	}
+	if q, err = parse(t.Size, ErrNonPositiveSize); err != nil {
+		return nil, nil, err
+	}
+
+	return p, q, nil
+}
+
+// ErrInvalidAlpha is returned by NewEWVWAP for smoothing factors outside of
+// (0, 1].
+var ErrInvalidAlpha = errors.New("smoothing factor must be in (0, 1]")
+
+// windowIndicator reports a value of the Result of a Calculator, so it
+// supports every window Option. The Calculator may be shared with other
+// window indicators.
+type windowIndicator struct {
+	name  string
+	calc  *Calculator
+	value func(Result) *big.Float
+}
+
+func (w *windowIndicator) Name() string { return w.name }
+
+func (w *windowIndicator) Update(t Trade) (*big.Float, error) {
+	r, err := w.calc.Update(t)
+	if err != nil {
+		return nil, err
+	}
+
+	return w.value(r), nil
+}
+
+// VWAPIndicator returns an Indicator named "vwap" reporting the VWAP of c.
+//
+// The indicators of a Calculator share its window, so that several of them
+// hold a single copy of the trades and evict them once: fed together through
+// Indicators.Update, the Calculator is updated once per trade. Fed one by
+// one, each of their Update calls updates the Calculator.
+func (c *Calculator) VWAPIndicator() Indicator {
+	return &windowIndicator{name: "vwap", calc: c, value: func(r Result) *big.Float {
+		return r.VWAP
+	}}
+}
+
+// VolumeIndicator returns an Indicator named "volume" reporting the total
+// volume traded within the window of c.
+// Like VWAPIndicator, it shares the window of c.
+func (c *Calculator) VolumeIndicator() Indicator {
+	return &windowIndicator{name: "volume", calc: c, value: func(r Result) *big.Float {
+		return r.Volume
+	}}
+}
+
+// TradeCountIndicator returns an Indicator named "count" reporting the number
+// of trades within the window of c.
+// Like VWAPIndicator, it shares the window of c.
+func (c *Calculator) TradeCountIndicator() Indicator {
+	return &windowIndicator{name: "count", calc: c, value: func(r Result) *big.Float {
+		return new(big.Float).SetPrec(prec).SetMode(mode).SetInt64(int64(r.Count))
+	}}
+}
+
+// AverageSizeIndicator returns an Indicator named "avgsize" reporting the
+// average size of the trades within the window of c.
+// Like VWAPIndicator, it shares the window of c.
+func (c *Calculator) AverageSizeIndicator() Indicator {
+	return &windowIndicator{name: "avgsize", calc: c, value: func(r Result) *big.Float {
+		avg := new(big.Float).SetPrec(prec).SetMode(mode)
+		if r.Count == 0 {
+			return avg
+		}
+		n := new(big.Float).SetPrec(prec).SetMode(mode).SetInt64(int64(r.Count))
+
+		return avg.Quo(r.Volume, n)
+	}}
+}
+
+// TWAP is a time-weighted average price Indicator named "twap".
+//
+// Within a window of windowWidth trades, the price of every trade is weighted
+// by the time elapsed until the next trade. If the trades in the window span
+// no time, the TWAP is the price of the last trade.
+type TWAP struct {
+	mu sync.Mutex
+
+	// pdts holds Price x Duration values and dts Durations, in nanoseconds,
+	// of the windowWidth-1 intervals between the trades in the window.
+	pdts *ringbuf.RingBuffer[*big.Float]
+	dts  *ringbuf.RingBuffer[*big.Float]
+
+	cumulativePriceTime *big.Float
+	cumulativeTime      *big.Float
+
+	// intervals is the maximum number of intervals in the window.
+	intervals int
+
+	// last is the price and time of the last trade received, which is
+	// weighted once the next trade is received.
+	lastPrice *big.Float
+	lastTime  time.Time
+}
+
+// NewTWAP creates a TWAP over a sliding window of windowWidth trades.
+// It returns ErrInvalidWindow if windowWidth is not positive.
+func NewTWAP(windowWidth int) (*TWAP, error) {
+	if windowWidth <= 0 {
+		return nil, fmt.Errorf("%w: %d", ErrInvalidWindow, windowWidth)
+	}
+
+	t := &TWAP{
+		intervals:           windowWidth - 1,
+		cumulativePriceTime: new(big.Float).SetPrec(prec).SetMode(mode),
+		cumulativeTime:      new(big.Float).SetPrec(prec).SetMode(mode),
+	}
+	if t.intervals > 0 {
+		t.pdts = ringbuf.NewRingBuffer[*big.Float](t.intervals)
+		t.dts = ringbuf.NewRingBuffer[*big.Float](t.intervals)
+	}
+
+	return t, nil
+}
+
+func (*TWAP) Name() string { return "twap" }
+
+// Update receives a Trade and calculates the new TWAP value. Trades are
+// validated like in Calculator.Update and a trade older than the previous
+// one is weighted as if it happened at the same time.
+func (t *TWAP) Update(trade Trade) (*big.Float, error) {
+	p, _, err := parsePriceSize(trade)
+	if err != nil {
+		return nil, err
+	}
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.lastPrice != nil && t.intervals > 0 {
+		if t.dts.Len() == t.intervals {
+			t.cumulativePriceTime.Sub(t.cumulativePriceTime, t.pdts.PopFront())
+			t.cumulativeTime.Sub(t.cumulativeTime, t.dts.PopFront())
+		}
+
+		elapsed := trade.Time.Sub(t.lastTime)
+		if elapsed < 0 {
+			elapsed = 0
+		}
+		dt := new(big.Float).SetPrec(prec).SetMode(mode).SetInt64(int64(elapsed))
+		pdt := new(big.Float).SetPrec(prec).SetMode(mode).Mul(t.lastPrice, dt)
+
+		t.cumulativePriceTime.Add(t.cumulativePriceTime, pdt)
+		t.pdts.PushBack(pdt)
+		t.cumulativeTime.Add(t.cumulativeTime, dt)
+		t.dts.PushBack(dt)
+	}
+	t.lastPrice = p
+	t.lastTime = trade.Time
+
+	if t.cumulativeTime.Sign() == 0 {
+		return new(big.Float).Set(p), nil
+	}
+
+	return new(big.Float).SetPrec(prec).SetMode(mode).Quo(t.cumulativePriceTime, t.cumulativeTime), nil
+}
+
+// EWVWAP is an exponentially weighted VWAP Indicator named "ewvwap".
+//
+// Instead of a sliding window, every trade decays the weight of the previous
+// ones by (1 - alpha):
+//
+//	EWVWAP = Σ(1-α)ⁿ·pₙ·qₙ / Σ(1-α)ⁿ·qₙ
+//
+// where n is the number of trades received after trade (pₙ, qₙ).
+type EWVWAP struct {
+	mu sync.Mutex
+
+	alpha      *big.Float
+	complement *big.Float
+
+	weightedTypicalPrice *big.Float
+	weightedVolume       *big.Float
+}
+
+// NewEWVWAP creates an EWVWAP with smoothing factor alpha. An alpha of
+// 2/(N+1) roughly matches a window of N trades.
+// It returns ErrInvalidAlpha if alpha is not in (0, 1].
+func NewEWVWAP(alpha float64) (*EWVWAP, error) {
+	if math.IsNaN(alpha) || alpha <= 0 || alpha > 1 {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidAlpha, alpha)
+	}
+
+	a := new(big.Float).SetPrec(prec).SetMode(mode).SetFloat64(alpha)
+	return &EWVWAP{
+		alpha:                a,
+		complement:           new(big.Float).SetPrec(prec).SetMode(mode).Sub(big.NewFloat(1), a),
+		weightedTypicalPrice: new(big.Float).SetPrec(prec).SetMode(mode),
+		weightedVolume:       new(big.Float).SetPrec(prec).SetMode(mode),
+	}, nil
+}
+
+func (*EWVWAP) Name() string { return "ewvwap" }
+
+// Update receives a Trade and calculates the new EWVWAP value. Trades are
+// validated like in Calculator.Update.
+func (e *EWVWAP) Update(t Trade) (*big.Float, error) {
+	p, q, err := parsePriceSize(t)
+	if err != nil {
+		return nil, err
+	}
+	pq := new(big.Float).SetPrec(prec).SetMode(mode).Mul(p, q)
+
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.weightedTypicalPrice.Mul(e.weightedTypicalPrice, e.complement)
+	e.weightedTypicalPrice.Add(e.weightedTypicalPrice, pq.Mul(pq, e.alpha))
+
+	e.weightedVolume.Mul(e.weightedVolume, e.complement)
+	e.weightedVolume.Add(e.weightedVolume, q.Mul(q, e.alpha))
+
+	return new(big.Float).SetPrec(prec).SetMode(mode).Quo(e.weightedTypicalPrice, e.weightedVolume), nil
+}
@@ -0,0 +1,184 @@
+package vwap
+
+import (
+	"math"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestIndicators_Update(t *testing.T) {
+	t.Run("Values in order", func(t *testing.T) {
+		calc, _ := NewCalculator(2)
+		is := Indicators{calc.VWAPIndicator(), calc.VolumeIndicator(), calc.TradeCountIndicator(), calc.AverageSizeIndicator()}
+
+		is.Update(Trade{Price: "1", Size: "2"})
+		is.Update(Trade{Price: "2", Size: "3"})
+		vs, err := is.Update(Trade{Price: "3", Size: "5"})
+
+		assert.Nil(t, err)
+		assert.Len(t, vs, 4)
+		for i, exp := range []struct {
+			name  string
+			value string
+		}{
+			{"vwap", "2.625"},
+			{"volume", "8"},
+			{"count", "2"},
+			{"avgsize", "4"},
+		} {
+			assert.Equal(t, exp.name, vs[i].Name)
+			assert.Equal(t, exp.value, vs[i].Value.String(), exp.name)
+		}
+	})
+
+	t.Run("Shared window", func(t *testing.T) {
+		calc, _ := NewCalculator(10)
+		is := Indicators{
+			calc.VWAPIndicator(),
+			calc.VolumeIndicator(),
+			calc.TradeCountIndicator(),
+			calc.AverageSizeIndicator(),
+			calc.VWAPIndicator(),
+		}
+
+		is.Update(Trade{Price: "1", Size: "2"})
+		is.Update(Trade{Price: "2", Size: "3"})
+		vs, err := is.Update(Trade{Price: "3", Size: "5"})
+
+		assert.Nil(t, err)
+		// Updated once per trade
+		assert.Equal(t, 3, calc.Value().Count)
+		for i, exp := range []string{"2.3", "10", "3", "3.333333333", "2.3"} {
+			assert.Equal(t, exp, vs[i].Value.String(), vs[i].Name)
+		}
+		// Values of the same Result are copies
+		assert.NotSame(t, vs[0].Value, vs[4].Value)
+	})
+
+	t.Run("Invalid trade", func(t *testing.T) {
+		calc, _ := NewCalculator(2)
+		twap, _ := NewTWAP(2)
+		ewvwap, _ := NewEWVWAP(0.5)
+
+		for _, i := range []Indicator{calc.VWAPIndicator(), twap, ewvwap} {
+			_, err := Indicators{i}.Update(Trade{Price: "1", Size: "0"})
+
+			assert.ErrorIs(t, err, ErrNonPositiveSize, i.Name())
+		}
+	})
+
+	t.Run("Rejected before any update", func(t *testing.T) {
+		calc, _ := NewCalculator(2, WithSideSplit())
+		twap, _ := NewTWAP(2)
+		ewvwap, _ := NewEWVWAP(0.5)
+		is := Indicators{twap, ewvwap, calc.VWAPIndicator()}
+		is.Update(Trade{Price: "10", Size: "1", Side: Buy})
+
+		// Without a side, only the Calculator rejects the trade
+		_, err := is.Update(Trade{Price: "20", Size: "1"})
+		assert.ErrorIs(t, err, ErrUnknownSide)
+
+		vs, _ := is.Update(Trade{Price: "10", Size: "1", Side: Sell})
+		for _, v := range vs {
+			assert.Equal(t, "10", v.Value.String(), v.Name)
+		}
+	})
+
+	t.Run("Window options", func(t *testing.T) {
+		calc, _ := NewCalculator(10, WithMaxAge(time.Second))
+		count := calc.TradeCountIndicator()
+		t0 := time.Date(2022, 9, 12, 14, 28, 52, 0, time.UTC)
+
+		count.Update(Trade{Price: "1", Size: "1", Time: t0})
+		count.Update(Trade{Price: "1", Size: "1", Time: t0.Add(time.Second)})
+		v, _ := count.Update(Trade{Price: "1", Size: "1", Time: t0.Add(2 * time.Second)})
+
+		assert.Equal(t, "2", v.String())
+	})
+}
+
+func TestTWAP_Update(t *testing.T) {
+	t.Run("Invalid window width", func(t *testing.T) {
+		_, err := NewTWAP(0)
+
+		assert.ErrorIs(t, err, ErrInvalidWindow)
+	})
+
+	t.Run("Weighted by time until the next trade", func(t *testing.T) {
+		var (
+			twap, _ = NewTWAP(3)
+			t0      = time.Date(2022, 9, 12, 14, 28, 52, 0, time.UTC)
+		)
+
+		v, _ := twap.Update(Trade{Price: "10", Size: "1", Time: t0})
+		assert.Equal(t, "10", v.String())
+
+		// Sizes do not matter
+		v, _ = twap.Update(Trade{Price: "20", Size: "100", Time: t0.Add(1 * time.Second)})
+		assert.Equal(t, "10", v.String())
+
+		// (10·1 + 20·3) / 4
+		v, _ = twap.Update(Trade{Price: "30", Size: "1", Time: t0.Add(4 * time.Second)})
+		assert.Equal(t, "17.5", v.String())
+
+		// Slides out the first interval: (20·3 + 30·1) / 4
+		v, _ = twap.Update(Trade{Price: "40", Size: "1", Time: t0.Add(5 * time.Second)})
+		assert.Equal(t, "22.5", v.String())
+	})
+
+	t.Run("No time elapsed", func(t *testing.T) {
+		twap, _ := NewTWAP(3)
+
+		twap.Update(Trade{Price: "10", Size: "1"})
+		v, _ := twap.Update(Trade{Price: "20", Size: "1"})
+
+		assert.Equal(t, "20", v.String())
+	})
+
+	t.Run("Window width 1", func(t *testing.T) {
+		twap, _ := NewTWAP(1)
+		t0 := time.Date(2022, 9, 12, 14, 28, 52, 0, time.UTC)
+
+		twap.Update(Trade{Price: "10", Size: "1", Time: t0})
+		v, _ := twap.Update(Trade{Price: "20", Size: "1", Time: t0.Add(time.Second)})
+
+		assert.Equal(t, "20", v.String())
+	})
+}
+
+func TestEWVWAP_Update(t *testing.T) {
+	t.Run("Invalid alpha", func(t *testing.T) {
+		for _, alpha := range []float64{0, -0.5, 1.5, math.NaN()} {
+			_, err := NewEWVWAP(alpha)
+
+			assert.ErrorIs(t, err, ErrInvalidAlpha)
+		}
+	})
+
+	t.Run("Exponential decay", func(t *testing.T) {
+		ewvwap, _ := NewEWVWAP(0.5)
+
+		// Σpq = 0.5·20, Σq = 0.5·2
+		v, _ := ewvwap.Update(Trade{Price: "10", Size: "2"})
+		assert.Equal(t, "10", v.String())
+
+		// Σpq = 0.5·10 + 0.5·20, Σq = 0.5·1 + 0.5·1
+		v, _ = ewvwap.Update(Trade{Price: "20", Size: "1"})
+		assert.Equal(t, "15", v.String())
+
+		// Σpq = 0.5·15 + 0.5·30, Σq = 0.5·1 + 0.5·1
+		v, _ = ewvwap.Update(Trade{Price: "30", Size: "1"})
+		assert.Equal(t, "22.5", v.String())
+	})
+
+	t.Run("Alpha 1 is the last price", func(t *testing.T) {
+		ewvwap, _ := NewEWVWAP(1)
+
+		ewvwap.Update(Trade{Price: "10", Size: "2"})
+		v, _ := ewvwap.Update(Trade{Price: "20", Size: "2"})
+
+		assert.Equal(t, "20", v.String())
+	})
+}
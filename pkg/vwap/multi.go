@@ -0,0 +1,152 @@
+package vwap
+
+import (
+	"fmt"
+	"math/big"
+	"sync"
+	"time"
+
+	"github.com/felipeblassioli/vwap/pkg/ringbuf"
+)
+
+// MultiCalculator calculates the VWAP over several window widths at once.
+//
+// Every trade is stored once, in a buffer as wide as the widest window, and
+// each window (horizon) keeps its own running sums which are updated as
+// trades enter the buffer and leave the horizon.
+type MultiCalculator struct {
+	mu sync.Mutex
+
+	// pqs, qs and ts hold the Price x Quantity values, quantities and times
+	// of the trades of the widest horizon.
+	pqs *ringbuf.RingBuffer[*big.Float]
+	qs  *ringbuf.RingBuffer[*big.Float]
+	ts  *ringbuf.RingBuffer[time.Time]
+
+	// maxWidth is the width of the widest horizon.
+	maxWidth int
+
+	horizons []*horizon
+
+	// newest is the time of the last trade received.
+	newest time.Time
+}
+
+// horizon holds the running sums of the last windowWidth trades.
+type horizon struct {
+	windowWidth            int
+	cumulativeTypicalPrice *big.Float
+	cumulativeVolume       *big.Float
+}
+
+// NewMultiCalculator creates a MultiCalculator with one sliding window per
+// given width. It returns ErrInvalidWindow if no width is given or any of
+// them is not positive.
+func NewMultiCalculator(windowWidths ...int) (*MultiCalculator, error) {
+	if len(windowWidths) == 0 {
+		return nil, fmt.Errorf("%w: no window widths", ErrInvalidWindow)
+	}
+
+	c := &MultiCalculator{}
+	for _, w := range windowWidths {
+		if w <= 0 {
+			return nil, fmt.Errorf("%w: %d", ErrInvalidWindow, w)
+		}
+		if w > c.maxWidth {
+			c.maxWidth = w
+		}
+		c.horizons = append(c.horizons, &horizon{
+			windowWidth:            w,
+			cumulativeTypicalPrice: new(big.Float).SetPrec(prec).SetMode(mode),
+			cumulativeVolume:       new(big.Float).SetPrec(prec).SetMode(mode),
+		})
+	}
+	c.pqs = ringbuf.NewRingBuffer[*big.Float](c.maxWidth)
+	c.qs = ringbuf.NewRingBuffer[*big.Float](c.maxWidth)
+	c.ts = ringbuf.NewRingBuffer[time.Time](c.maxWidth)
+
+	return c, nil
+}
+
+// Update receives a Trade and calculates the new VWAP value of every
+// horizon. It returns one Result per window width, in the order given to
+// NewMultiCalculator.
+//
+// Trades are validated like in Calculator.Update.
+func (c *MultiCalculator) Update(t Trade) ([]Result, error) {
+	p, err := parse(t.Price, ErrNonPositivePrice)
+	if err != nil {
+		return nil, err
+	}
+
+	q, err := parse(t.Size, ErrNonPositiveSize)
+	if err != nil {
+		return nil, err
+	}
+	pq := new(big.Float).SetPrec(prec).SetMode(mode).Mul(p, q)
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	// Every horizon that is full discards the trade that is windowWidth
+	// trades behind the received one.
+	n := c.pqs.Len()
+	for _, h := range c.horizons {
+		if n >= h.windowWidth {
+			i := n - h.windowWidth
+			h.cumulativeTypicalPrice.Sub(h.cumulativeTypicalPrice, c.pqs.At(i))
+			h.cumulativeVolume.Sub(h.cumulativeVolume, c.qs.At(i))
+		}
+	}
+	if n == c.maxWidth {
+		c.pqs.PopFront()
+		c.qs.PopFront()
+		c.ts.PopFront()
+	}
+
+	c.pqs.PushBack(pq)
+	c.qs.PushBack(q)
+	c.ts.PushBack(t.Time)
+	if t.Time.After(c.newest) {
+		c.newest = t.Time
+	}
+	for _, h := range c.horizons {
+		h.cumulativeTypicalPrice.Add(h.cumulativeTypicalPrice, pq)
+		h.cumulativeVolume.Add(h.cumulativeVolume, q)
+	}
+
+	return c.results(), nil
+}
+
+// Value returns the current state of every horizon without updating them.
+func (c *MultiCalculator) Value() []Result {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	return c.results()
+}
+
+// results must be called with c.mu held.
+func (c *MultiCalculator) results() []Result {
+	n := c.pqs.Len()
+	rs := make([]Result, 0, len(c.horizons))
+	for _, h := range c.horizons {
+		r := Result{
+			VWAP:   new(big.Float).SetPrec(prec).SetMode(mode),
+			Volume: new(big.Float).Set(h.cumulativeVolume),
+			Count:  n,
+			Full:   n >= h.windowWidth,
+		}
+		if r.Full {
+			r.Count = h.windowWidth
+		}
+		if r.Count > 0 {
+			r.VWAP.Quo(h.cumulativeTypicalPrice, h.cumulativeVolume)
+			r.Oldest = c.ts.At(n - r.Count)
+			r.Newest = c.newest
+		}
+		rs = append(rs, r)
+	}
+
+	return rs
+}
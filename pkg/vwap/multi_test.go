@@ -0,0 +1,82 @@
+package vwap
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNewMultiCalculator(t *testing.T) {
+	tests := [][]int{
+		nil,
+		{0},
+		{10, -1},
+	}
+
+	for _, widths := range tests {
+		calc, err := NewMultiCalculator(widths...)
+
+		assert.Nil(t, calc)
+		assert.ErrorIs(t, err, ErrInvalidWindow, "%v", widths)
+	}
+}
+
+func TestMultiCalculator_Update(t *testing.T) {
+	t.Run("Data point parse failure", func(t *testing.T) {
+		calc, _ := NewMultiCalculator(1, 2)
+
+		_, err := calc.Update(Trade{Price: "not-a-float", Size: "1"})
+
+		assert.ErrorIs(t, err, ErrFloatParse)
+	})
+
+	t.Run("Matches one Calculator per width", func(t *testing.T) {
+		var (
+			widths     = []int{3, 1, 5, 2}
+			prices     = []string{"1", "2", "3", "4", "5", "6", "7", "8"}
+			quantities = []string{"2", "3", "5", "7", "11", "13", "17", "19"}
+			t0         = time.Date(2022, 9, 12, 14, 28, 52, 0, time.UTC)
+		)
+
+		multi, _ := NewMultiCalculator(widths...)
+		calcs := make([]*Calculator, 0, len(widths))
+		for _, w := range widths {
+			calc, _ := NewCalculator(w)
+			calcs = append(calcs, calc)
+		}
+
+		for i := range prices {
+			trade := Trade{
+				Price: prices[i],
+				Size:  quantities[i],
+				Time:  t0.Add(time.Duration(i) * time.Second),
+			}
+
+			act, err := multi.Update(trade)
+			assert.Nil(t, err)
+			assert.Len(t, act, len(widths))
+
+			for j, calc := range calcs {
+				exp, _ := calc.Update(trade)
+
+				assert.Equal(t, 0, exp.VWAP.Cmp(act[j].VWAP), "width %d: %v !== %v", widths[j], exp, act[j])
+				assert.Equal(t, 0, exp.Volume.Cmp(act[j].Volume), "width %d", widths[j])
+				assert.Equal(t, exp.Count, act[j].Count, "width %d", widths[j])
+				assert.Equal(t, exp.Full, act[j].Full, "width %d", widths[j])
+				assert.Equal(t, exp.Oldest, act[j].Oldest, "width %d", widths[j])
+				assert.Equal(t, exp.Newest, act[j].Newest, "width %d", widths[j])
+			}
+		}
+	})
+
+	t.Run("Empty windows", func(t *testing.T) {
+		calc, _ := NewMultiCalculator(1, 2)
+
+		for _, r := range calc.Value() {
+			assert.Equal(t, 0, r.VWAP.Sign())
+			assert.Equal(t, 0, r.Count)
+			assert.False(t, r.Full)
+		}
+	})
+}
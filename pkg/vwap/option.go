@@ -0,0 +1,135 @@
+package vwap
+
+import (
+	"fmt"
+	"math"
+	"math/big"
+	"time"
+
+	"github.com/felipeblassioli/vwap/pkg/ringbuf"
+)
+
+// Option configures optional behaviour of a Calculator.
+type Option func(*Calculator) error
+
+// WithMaxAge turns the count window into a time window: on every update,
+// trades older than maxAge relative to the newest trade's Time are
+// discarded from the calculation.
+//
+// Trades are kept in the order they are received, so a trade received out of order never moves the window
+// back in time, and is discarded right away if it is older than maxAge.
+//
+// The window width still bounds the number of trades in the window, so it
+// must be large enough to hold maxAge worth of trades.
+func WithMaxAge(maxAge time.Duration) Option {
+	return func(c *Calculator) error {
+		if maxAge <= 0 {
+			return fmt.Errorf("%w: max age %s", ErrInvalidWindow, maxAge)
+		}
+		c.maxAge = maxAge
+
+		return nil
+	}
+}
+
+// WithVolumeWindow turns the count window into a volume window: on every
+// update, the oldest trades are discarded until the trades in the window sum
+// to at most volume, discarding part of the oldest trade's size if needed.
+// The VWAP is then the average price of the last volume units traded.
+//
+// The window width still bounds the number of trades in the window, so it
+// must be large enough to hold volume worth of trades.
+func WithVolumeWindow(volume string) Option {
+	return func(c *Calculator) error {
+		v, err := parse(volume, ErrInvalidWindow)
+		if err != nil {
+			return err
+		}
+		c.maxVolume = v
+
+		return nil
+	}
+}
+
+// WithBands enables VWAP standard-deviation bands. Every Result holds one
+// Band for each multiplier k, in the given order, at VWAP ± k·σ where σ is
+// the volume-weighted standard deviation of the prices in the window.
+func WithBands(ks ...float64) Option {
+	return func(c *Calculator) error {
+		for _, k := range ks {
+			if k <= 0 || math.IsInf(k, 0) || math.IsNaN(k) {
+				return fmt.Errorf("%w: %v", ErrInvalidBand, k)
+			}
+		}
+		c.bands = append(c.bands, ks...)
+		if c.cumulativeSquaredTypicalPrice == nil {
+			c.trades.withP2Q()
+			c.cumulativeSquaredTypicalPrice = new(big.Float).SetPrec(prec).SetMode(mode)
+		}
+
+		return nil
+	}
+}
+
+// WithSideSplit enables tracking the buy-aggressor and sell-aggressor VWAPs
+// and volumes within the window, as well as the net order-flow imbalance.
+//
+// Every Trade fed to the Calculator must then have a known Side.
+func WithSideSplit() Option {
+	return func(c *Calculator) error {
+		if c.buys == nil {
+			c.buys = newSideTotals()
+			c.sells = newSideTotals()
+		}
+
+		return nil
+	}
+}
+
+// WithPercentiles enables rolling volume-weighted percentiles of the prices
+// in the window. Every Result holds one Percentile for each of ps, in the
+// given order; use 50 for the median.
+func WithPercentiles(ps ...float64) Option {
+	return func(c *Calculator) error {
+		for _, p := range ps {
+			//nolint:gomnd // Percent
+			if p < 0 || p > 100 || math.IsNaN(p) {
+				return fmt.Errorf("%w: %v", ErrInvalidPercentile, p)
+			}
+		}
+		c.percentiles = append(c.percentiles, ps...)
+		if c.prices == nil {
+			c.prices = newOrderStats()
+		}
+
+		return nil
+	}
+}
+
+// WithVolumeProfile enables the volume profile of the trades in the window,
+// with buckets of tickSize. See Calculator.VolumeProfile.
+func WithVolumeProfile(tickSize string) Option {
+	return func(c *Calculator) error {
+		profile, err := NewVolumeProfile(tickSize)
+		if err != nil {
+			return err
+		}
+		c.profile = profile
+		c.trades.withBuckets()
+
+		return nil
+	}
+}
+
+// WithRange enables the rolling high and low prices of the trades in the
+// window. Every Result holds them in High and Low.
+func WithRange() Option {
+	return func(c *Calculator) error {
+		if c.highs == nil {
+			c.highs = ringbuf.NewMonotonicDeque(func(a, b *big.Float) bool { return a.Cmp(b) > 0 })
+			c.lows = ringbuf.NewMonotonicDeque(func(a, b *big.Float) bool { return a.Cmp(b) < 0 })
+		}
+
+		return nil
+	}
+}
@@ -0,0 +1,199 @@
+package vwap
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestWithMaxAge(t *testing.T) {
+	t.Run("Invalid max age", func(t *testing.T) {
+		for _, d := range []time.Duration{0, -time.Second} {
+			_, err := NewCalculator(1, WithMaxAge(d))
+
+			assert.ErrorIs(t, err, ErrInvalidWindow)
+		}
+	})
+
+	t.Run("Discards trades older than max age", func(t *testing.T) {
+		var (
+			calc, _ = NewCalculator(10, WithMaxAge(2*time.Second), WithBands(1))
+			t0      = time.Date(2022, 9, 12, 14, 28, 52, 0, time.UTC)
+		)
+
+		calc.Update(Trade{Price: "1", Size: "1", Time: t0})
+		calc.Update(Trade{Price: "2", Size: "1", Time: t0.Add(1 * time.Second)})
+		r, _ := calc.Update(Trade{Price: "3", Size: "1", Time: t0.Add(2 * time.Second)})
+		// Trades exactly maxAge old are kept
+		assert.Equal(t, 3, r.Count)
+		assert.Equal(t, "2", r.VWAP.String())
+
+		r, _ = calc.Update(Trade{Price: "4", Size: "1", Time: t0.Add(3 * time.Second)})
+		assert.Equal(t, 3, r.Count)
+		assert.Equal(t, "3", r.VWAP.String())
+		assert.Equal(t, t0.Add(time.Second), r.Oldest)
+
+		// A gap evicts everything but the received trade
+		r, _ = calc.Update(Trade{Price: "5", Size: "2", Time: t0.Add(time.Minute)})
+		assert.Equal(t, 1, r.Count)
+		assert.Equal(t, "5", r.VWAP.String())
+		assert.Equal(t, "2", r.Volume.String())
+		assert.Equal(t, 0, r.StdDev.Sign())
+	})
+
+	t.Run("Out-of-order trade", func(t *testing.T) {
+		var (
+			calc, _ = NewCalculator(10, WithMaxAge(2*time.Second))
+			t0      = time.Date(2022, 9, 12, 14, 28, 52, 0, time.UTC)
+		)
+
+		calc.Update(Trade{Price: "1", Size: "1", Time: t0.Add(4 * time.Second)})
+		calc.Update(Trade{Price: "2", Size: "1", Time: t0.Add(5 * time.Second)})
+		// Older than max age relative to the newest trade
+		r, _ := calc.Update(Trade{Price: "3", Size: "1", Time: t0.Add(2 * time.Second)})
+		assert.Equal(t, 2, r.Count)
+		assert.Equal(t, t0.Add(4*time.Second), r.Oldest)
+		assert.Equal(t, t0.Add(5*time.Second), r.Newest)
+
+		r, _ = calc.Update(Trade{Price: "4", Size: "1", Time: t0.Add(6500 * time.Millisecond)})
+		assert.Equal(t, 2, r.Count)
+		assert.Equal(t, "3", r.VWAP.String())
+		assert.Equal(t, t0.Add(6500*time.Millisecond), r.Newest)
+	})
+
+	t.Run("Window width still bounds the window", func(t *testing.T) {
+		calc, _ := NewCalculator(2, WithMaxAge(time.Hour))
+
+		calc.Update(Trade{Price: "1", Size: "1"})
+		calc.Update(Trade{Price: "2", Size: "1"})
+		r, _ := calc.Update(Trade{Price: "3", Size: "1"})
+
+		assert.Equal(t, 2, r.Count)
+		assert.Equal(t, "2.5", r.VWAP.String())
+	})
+}
+
+func TestWithVolumeWindow(t *testing.T) {
+	t.Run("Invalid volume", func(t *testing.T) {
+		for _, v := range []string{"0", "-1"} {
+			_, err := NewCalculator(1, WithVolumeWindow(v))
+
+			assert.ErrorIs(t, err, ErrInvalidWindow)
+		}
+
+		_, err := NewCalculator(1, WithVolumeWindow("not-a-float"))
+		assert.ErrorIs(t, err, ErrFloatParse)
+	})
+
+	t.Run("Partially discards the oldest trade", func(t *testing.T) {
+		calc, _ := NewCalculator(10, WithVolumeWindow("10"))
+
+		r, _ := calc.Update(Trade{Price: "1", Size: "4"})
+		assert.Equal(t, "1", r.VWAP.String())
+		assert.Equal(t, "4", r.Volume.String())
+
+		r, _ = calc.Update(Trade{Price: "2", Size: "4"})
+		assert.Equal(t, "1.5", r.VWAP.String())
+		assert.Equal(t, "8", r.Volume.String())
+
+		// 2 units of the first trade remain: (1·2 + 2·4 + 3·4) / 10
+		r, _ = calc.Update(Trade{Price: "3", Size: "4"})
+		assert.Equal(t, "2.2", r.VWAP.String())
+		assert.Equal(t, "10", r.Volume.String())
+		assert.Equal(t, 3, r.Count)
+
+		// The first trade is discarded entirely, 1 unit of the second
+		// remains: (2·1 + 3·4 + 4·5) / 10
+		r, _ = calc.Update(Trade{Price: "4", Size: "5"})
+		assert.Equal(t, "3.4", r.VWAP.String())
+		assert.Equal(t, "10", r.Volume.String())
+		assert.Equal(t, 3, r.Count)
+	})
+
+	t.Run("Trade bigger than the window", func(t *testing.T) {
+		calc, _ := NewCalculator(10, WithVolumeWindow("10"), WithBands(1), WithSideSplit())
+
+		calc.Update(Trade{Price: "1", Size: "4", Side: Buy})
+		r, _ := calc.Update(Trade{Price: "7", Size: "50", Side: Sell})
+
+		assert.Equal(t, "7", r.VWAP.String())
+		assert.Equal(t, "10", r.Volume.String())
+		assert.Equal(t, 1, r.Count)
+		assert.Equal(t, 0, r.StdDev.Sign())
+		assert.Equal(t, 0, r.Buy.Count)
+		assert.Equal(t, "7", r.Sell.VWAP.String())
+		assert.Equal(t, "10", r.Sell.Volume.String())
+		assert.Equal(t, "-1", r.Imbalance.String())
+	})
+
+	t.Run("Partial discard keeps bands and sides consistent", func(t *testing.T) {
+		calc, _ := NewCalculator(10, WithVolumeWindow("4"), WithBands(1), WithSideSplit())
+
+		calc.Update(Trade{Price: "1", Size: "2", Side: Buy})
+		calc.Update(Trade{Price: "3", Size: "2", Side: Sell})
+		// 1 unit of the first trade remains
+		r, _ := calc.Update(Trade{Price: "3", Size: "1", Side: Sell})
+
+		// VWAP = (1 + 6 + 3) / 4, σ² = (1 + 18 + 9) / 4 - 2.5² = 0.75
+		assert.Equal(t, "2.5", r.VWAP.String())
+		sd, _ := r.StdDev.Float64()
+		assert.InDelta(t, 0.8660254037844386, sd, 1e-12)
+		assert.Equal(t, "1", r.Buy.VWAP.String())
+		assert.Equal(t, "1", r.Buy.Volume.String())
+		assert.Equal(t, 1, r.Buy.Count)
+		assert.Equal(t, "3", r.Sell.VWAP.String())
+		assert.Equal(t, "3", r.Sell.Volume.String())
+		assert.Equal(t, "-0.5", r.Imbalance.String())
+	})
+}
+
+func TestWithRange(t *testing.T) {
+	rangeOf := func(r Result) []string {
+		return []string{r.Low.String(), r.High.String()}
+	}
+
+	t.Run("Disabled", func(t *testing.T) {
+		calc, _ := NewCalculator(1)
+		r, _ := calc.Update(Trade{Price: "1", Size: "1"})
+		assert.Nil(t, r.High)
+		assert.Nil(t, r.Low)
+	})
+
+	t.Run("Empty window", func(t *testing.T) {
+		calc, _ := NewCalculator(1, WithRange())
+		assert.Equal(t, []string{"0", "0"}, rangeOf(calc.Value()))
+	})
+
+	t.Run("Slides with the window", func(t *testing.T) {
+		calc, _ := NewCalculator(3, WithRange())
+		for _, tc := range []struct {
+			price string
+			exp   []string
+		}{
+			{"20", []string{"20", "20"}},
+			{"10", []string{"10", "20"}},
+			{"30", []string{"10", "30"}},
+			{"25", []string{"10", "30"}},
+			{"26", []string{"25", "30"}},
+			{"27", []string{"25", "27"}},
+		} {
+			r, _ := calc.Update(Trade{Price: tc.price, Size: "1"})
+			assert.Equal(t, tc.exp, rangeOf(r), "after %s", tc.price)
+		}
+	})
+
+	t.Run("Time and volume windows", func(t *testing.T) {
+		t0 := time.Date(2022, 9, 12, 14, 28, 52, 0, time.UTC)
+		calc, _ := NewCalculator(10, WithMaxAge(time.Minute), WithVolumeWindow("3"), WithRange())
+
+		calc.Update(Trade{Price: "50", Size: "1", Time: t0})
+		calc.Update(Trade{Price: "10", Size: "2", Time: t0.Add(time.Second)})
+		// Part of the second trade remains
+		r, _ := calc.Update(Trade{Price: "30", Size: "2", Time: t0.Add(2 * time.Second)})
+		assert.Equal(t, []string{"10", "30"}, rangeOf(r))
+
+		r, _ = calc.Update(Trade{Price: "20", Size: "1", Time: t0.Add(2 * time.Minute)})
+		assert.Equal(t, []string{"20", "20"}, rangeOf(r))
+	})
+}
@@ -0,0 +1,202 @@
+package vwap
+
+import (
+	"math/big"
+	"math/rand"
+)
+
+// Percentile is a volume-weighted percentile of the prices in the window:
+// the lowest price at or below which at least P percent of the window's
+// volume traded. The median is the 50th percentile.
+type Percentile struct {
+	P     float64
+	Price *big.Float
+}
+
+// orderStats is a treap of the prices in the window, ordered by price. Every
+// node holds the volume traded at its price and the volume of its subtree,
+// so that trades are added and removed, and percentiles are found, in
+// O(log n) rather than by sorting the window.
+type orderStats struct {
+	root *priceNode
+	rand *rand.Rand
+}
+
+// priceNode is a price level of an orderStats.
+type priceNode struct {
+	price *big.Float
+	// volume and count are the volume and number of the trades at price.
+	volume *big.Float
+	count  int
+	// sum is the volume of the subtree rooted at this node.
+	sum *big.Float
+
+	priority    int64
+	left, right *priceNode
+}
+
+func newOrderStats() *orderStats {
+	return &orderStats{
+		// The priorities only need to be random enough to keep the treap
+		// balanced, and a fixed seed keeps the calculations reproducible.
+		//nolint:gosec // Not used for security
+		rand: rand.New(rand.NewSource(1)),
+	}
+}
+
+// add adds a trade of q units at price p.
+func (s *orderStats) add(p, q *big.Float) {
+	s.root = s.insert(s.root, p, q)
+}
+
+// sub subtracts q units from the trades at price p, and removes trades of
+// them from the count. Trades are partially evicted with trades set to 0.
+//
+// The price level is removed once it holds no trades.
+func (s *orderStats) sub(p, q *big.Float, trades int) {
+	s.root = s.remove(s.root, p, q, trades)
+}
+
+func (s *orderStats) insert(n *priceNode, p, q *big.Float) *priceNode {
+	if n == nil {
+		return &priceNode{
+			price:    p,
+			volume:   new(big.Float).SetPrec(prec).SetMode(mode).Set(q),
+			count:    1,
+			sum:      new(big.Float).SetPrec(prec).SetMode(mode).Set(q),
+			priority: s.rand.Int63(),
+		}
+	}
+
+	switch cmp := p.Cmp(n.price); {
+	case cmp == 0:
+		n.volume.Add(n.volume, q)
+		n.count++
+	case cmp < 0:
+		n.left = s.insert(n.left, p, q)
+		if n.left.priority > n.priority {
+			return n.rotateRight()
+		}
+	default:
+		n.right = s.insert(n.right, p, q)
+		if n.right.priority > n.priority {
+			return n.rotateLeft()
+		}
+	}
+	n.update()
+
+	return n
+}
+
+func (s *orderStats) remove(n *priceNode, p, q *big.Float, trades int) *priceNode {
+	if n == nil {
+		return nil
+	}
+
+	switch cmp := p.Cmp(n.price); {
+	case cmp == 0:
+		n.volume.Sub(n.volume, q)
+		n.count -= trades
+		if n.count == 0 {
+			return n.delete()
+		}
+	case cmp < 0:
+		n.left = s.remove(n.left, p, q, trades)
+	default:
+		n.right = s.remove(n.right, p, q, trades)
+	}
+	n.update()
+
+	return n
+}
+
+// percentile returns the lowest price at or below which at least pct
+// percent of the volume traded, or zero if there are no trades.
+func (s *orderStats) percentile(pct float64) *big.Float {
+	price := new(big.Float).SetPrec(prec).SetMode(mode)
+	if s.root == nil {
+		return price
+	}
+
+	var (
+		//nolint:gomnd // Percent
+		target = new(big.Float).SetPrec(prec).SetMode(mode).SetFloat64(pct / 100)
+		below  = new(big.Float).SetPrec(prec).SetMode(mode)
+	)
+	target.Mul(target, s.root.sum)
+	for n := s.root; n != nil; {
+		price.Set(n.price)
+		if n.left != nil && n.left.sum.Cmp(target) >= 0 {
+			n = n.left
+			continue
+		}
+
+		below.Set(n.volume)
+		if n.left != nil {
+			below.Add(below, n.left.sum)
+		}
+		if below.Cmp(target) >= 0 {
+			break
+		}
+		target.Sub(target, below)
+		// Rounding errors of the volumes may leave target beyond the
+		// highest price, which is then the percentile.
+		n = n.right
+	}
+
+	return price
+}
+
+// update recalculates the volume of the subtree rooted at n.
+func (n *priceNode) update() {
+	n.sum.Set(n.volume)
+	if n.left != nil {
+		n.sum.Add(n.sum, n.left.sum)
+	}
+	if n.right != nil {
+		n.sum.Add(n.sum, n.right.sum)
+	}
+}
+
+// rotateRight makes the left child of n the root of its subtree.
+func (n *priceNode) rotateRight() *priceNode {
+	l := n.left
+	n.left = l.right
+	n.update()
+	l.right = n
+	l.update()
+
+	return l
+}
+
+// rotateLeft makes the right child of n the root of its subtree.
+func (n *priceNode) rotateLeft() *priceNode {
+	r := n.right
+	n.right = r.left
+	n.update()
+	r.left = n
+	r.update()
+
+	return r
+}
+
+// delete removes n from its subtree, and returns the new root of the
+// subtree.
+func (n *priceNode) delete() *priceNode {
+	switch {
+	case n.left == nil:
+		return n.right
+	case n.right == nil:
+		return n.left
+	case n.left.priority > n.right.priority:
+		root := n.rotateRight()
+		root.right = n.delete()
+		root.update()
+		return root
+	default:
+		root := n.rotateLeft()
+		root.left = n.delete()
+		root.update()
+		return root
+	}
+}
@@ -0,0 +1,129 @@
+package vwap
+
+import (
+	"math/big"
+	"sort"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestWithPercentiles(t *testing.T) {
+	percentiles := func(r Result) []string {
+		var ps []string
+		for _, p := range r.Percentiles {
+			ps = append(ps, p.Price.String())
+		}
+		return ps
+	}
+
+	t.Run("Disabled", func(t *testing.T) {
+		calc, _ := NewCalculator(1)
+
+		r, _ := calc.Update(Trade{Price: "1", Size: "1"})
+
+		assert.Nil(t, r.Percentiles)
+	})
+
+	t.Run("Invalid percentile", func(t *testing.T) {
+		for _, p := range []float64{-1, 100.1} {
+			calc, err := NewCalculator(1, WithPercentiles(p))
+
+			assert.Nil(t, calc)
+			assert.ErrorIs(t, err, ErrInvalidPercentile)
+		}
+	})
+
+	t.Run("Empty window", func(t *testing.T) {
+		calc, _ := NewCalculator(1, WithPercentiles(50))
+
+		assert.Equal(t, []string{"0"}, percentiles(calc.Value()))
+	})
+
+	t.Run("Sliding window", func(t *testing.T) {
+		calc, _ := NewCalculator(3, WithPercentiles(0, 5, 50, 95, 100))
+
+		calc.Update(Trade{Price: "10", Size: "1"})
+		calc.Update(Trade{Price: "30", Size: "1"})
+		r, _ := calc.Update(Trade{Price: "20", Size: "2"})
+		// Cumulative volume: 10 -> 1, 20 -> 3, 30 -> 4
+		assert.Equal(t, []string{"10", "10", "20", "30", "30"}, percentiles(r))
+		assert.Equal(t, 5.0, r.Percentiles[1].P)
+
+		r, _ = calc.Update(Trade{Price: "40", Size: "4"})
+		// Cumulative volume: 20 -> 2, 30 -> 3, 40 -> 7
+		assert.Equal(t, []string{"20", "20", "40", "40", "40"}, percentiles(r))
+
+		// Same price twice, then out of the window one at a time
+		calc.Update(Trade{Price: "40", Size: "1"})
+		r, _ = calc.Update(Trade{Price: "50", Size: "1"})
+		assert.Equal(t, []string{"40", "40", "40", "50", "50"}, percentiles(r))
+		calc.Update(Trade{Price: "60", Size: "1"})
+		r, _ = calc.Update(Trade{Price: "60", Size: "1"})
+		assert.Equal(t, []string{"50", "50", "60", "60", "60"}, percentiles(r))
+	})
+
+	t.Run("Volume window", func(t *testing.T) {
+		calc, _ := NewCalculator(10, WithVolumeWindow("3"), WithPercentiles(0, 30, 50))
+
+		calc.Update(Trade{Price: "10", Size: "2"})
+		r, _ := calc.Update(Trade{Price: "20", Size: "2"})
+		// Half of the trade at 10 is discarded
+		assert.Equal(t, []string{"10", "10", "20"}, percentiles(r))
+
+		r, _ = calc.Update(Trade{Price: "30", Size: "1"})
+		assert.Equal(t, []string{"20", "20", "20"}, percentiles(r))
+	})
+
+	t.Run("Same as sorting the window", func(t *testing.T) {
+		var (
+			trades  = loadTrades(t)
+			ps      = []float64{0, 5, 25, 50, 75, 95, 100}
+			calc, _ = NewCalculator(50, WithPercentiles(ps...))
+		)
+		for i, trade := range trades {
+			r, err := calc.Update(trade)
+			assert.Nil(t, err)
+
+			first := 0
+			if i >= 50 {
+				first = i - 49
+			}
+			for j, p := range ps {
+				assert.Equal(t, sortedPercentile(trades[first:i+1], p).String(), r.Percentiles[j].Price.String())
+			}
+		}
+	})
+}
+
+// sortedPercentile returns the volume-weighted percentile of the trades by
+// sorting them.
+func sortedPercentile(trades []Trade, pct float64) *big.Float {
+	type trade struct{ p, q *big.Float }
+
+	var (
+		ts    = make([]trade, 0, len(trades))
+		total = new(big.Float).SetPrec(prec).SetMode(mode)
+	)
+	for _, t := range trades {
+		p, _ := parse(t.Price, ErrNonPositivePrice)
+		q, _ := parse(t.Size, ErrNonPositiveSize)
+		ts = append(ts, trade{p, q})
+		total.Add(total, q)
+	}
+	sort.SliceStable(ts, func(i, j int) bool { return ts[i].p.Cmp(ts[j].p) < 0 })
+
+	var (
+		target     = new(big.Float).SetPrec(prec).SetMode(mode).SetFloat64(pct / 100)
+		cumulative = new(big.Float).SetPrec(prec).SetMode(mode)
+	)
+	target.Mul(target, total)
+	for _, t := range ts {
+		cumulative.Add(cumulative, t.q)
+		if cumulative.Cmp(target) >= 0 {
+			return t.p
+		}
+	}
+
+	return ts[len(ts)-1].p
+}
@@ -0,0 +1,276 @@
+package vwap
+
+import (
+	"errors"
+	"fmt"
+	"math/big"
+	"sort"
+	"sync"
+)
+
+var (
+	// ErrInvalidTickSize is returned by NewVolumeProfile and
+	// WithVolumeProfile for tick sizes that are not positive and finite.
+	ErrInvalidTickSize = errors.New("tick size must be positive")
+
+	// ErrPriceOutOfRange is returned by VolumeProfile.Update, and by
+	// Calculator.Update WithVolumeProfile, for prices too many ticks away
+	// from zero to be bucketed.
+	ErrPriceOutOfRange = errors.New("price out of range for the tick size")
+)
+
+// valueAreaShare is the share of the volume in the value area of a Profile.
+const valueAreaShare = 0.7
+
+// ProfileLevel is a price bucket of a Profile.
+type ProfileLevel struct {
+	// Price is the lower bound of the bucket, a multiple of the tick size.
+	Price *big.Float
+	// Volume and Count are the volume and number of the trades whose
+	// price is in [Price, Price+TickSize).
+	Volume *big.Float
+	Count  int
+}
+
+// Profile is a volume-at-price histogram.
+//
+// The big.Float values are copies owned by the caller.
+type Profile struct {
+	TickSize *big.Float
+	// Levels are the buckets holding trades, by ascending price.
+	Levels []ProfileLevel
+	// Volume is the volume of all Levels.
+	Volume *big.Float
+	// POC, the point of control, is the price of the level with the most
+	// volume, the lowest one on ties. It is nil if there are no Levels.
+	POC *big.Float
+	// ValueAreaLow and ValueAreaHigh are the prices of the lowest and
+	// highest levels of the value area: the levels around the POC holding
+	// 70% of the volume. It is grown from the POC one level at a time
+	// towards the neighbour level with more volume, the lower one on ties.
+	// They are nil if there are no Levels.
+	ValueAreaLow  *big.Float
+	ValueAreaHigh *big.Float
+}
+
+// VolumeProfile is a volume-at-price histogram of trades, bucketed by tick
+// size.
+//
+// Created by NewVolumeProfile it holds every trade it is updated with, e.g.
+// for a session profile. A Calculator created WithVolumeProfile holds one
+// of the trades in its window instead. See Calculator.VolumeProfile.
+type VolumeProfile struct {
+	mu sync.Mutex
+
+	tickSize *big.Float
+	// tick is the exact value of the tick size, for bucketing prices
+	// exactly. See bucket.
+	tick *big.Rat
+
+	// levels are the buckets holding trades by their index, the lower bound
+	// of the bucket divided by the tick size.
+	levels map[int64]*profileLevel
+	volume *big.Float
+}
+
+// profileLevel holds the running sums of a bucket.
+type profileLevel struct {
+	volume *big.Float
+	count  int
+}
+
+// NewVolumeProfile returns an empty VolumeProfile with buckets of tickSize,
+// e.g. "0.01".
+func NewVolumeProfile(tickSize string) (*VolumeProfile, error) {
+	tick, err := parse(tickSize, ErrInvalidTickSize)
+	if err != nil {
+		return nil, err
+	}
+
+	return &VolumeProfile{
+		tickSize: tick,
+		tick:     exactRat(tickSize, tick),
+		levels:   make(map[int64]*profileLevel),
+		volume:   new(big.Float).SetPrec(prec).SetMode(mode),
+	}, nil
+}
+
+// Update adds a trade to the profile. Trades with an unparsable, infinite
+// or non-positive price or size are rejected with the same errors as
+// Calculator.Update.
+func (v *VolumeProfile) Update(t Trade) error {
+	p, err := parse(t.Price, ErrNonPositivePrice)
+	if err != nil {
+		return err
+	}
+
+	q, err := parse(t.Size, ErrNonPositiveSize)
+	if err != nil {
+		return err
+	}
+
+	i, err := v.bucket(t.Price, p)
+	if err != nil {
+		return err
+	}
+
+	v.mu.Lock()
+	defer v.mu.Unlock()
+
+	v.add(i, q)
+
+	return nil
+}
+
+// bucket returns the index of the bucket of price p, parsed from the decimal
+// string price.
+//
+// The index is calculated from the decimal value of the price rather than
+// from p, which is rounded to binary: a price on a tick boundary, e.g. 0.3
+// with a tick size of 0.1, is in the bucket starting at it rather than in the
+// one below.
+//
+// It returns ErrPriceOutOfRange if the index does not fit in an int64.
+func (v *VolumeProfile) bucket(price string, p *big.Float) (int64, error) {
+	r := new(big.Rat).Quo(exactRat(price, p), v.tick)
+	// Prices are positive, so Euclidean division is floor division.
+	i := new(big.Int).Div(r.Num(), r.Denom())
+	if !i.IsInt64() {
+		return 0, fmt.Errorf("%w: %s at tick size %s", ErrPriceOutOfRange, price, v.tickSize.Text('g', -1))
+	}
+
+	return i.Int64(), nil
+}
+
+// exactRat returns the value of the decimal string s, or the value of f, s
+// parsed to a big.Float, if big.Rat does not accept s.
+func exactRat(s string, f *big.Float) *big.Rat {
+	if r, ok := new(big.Rat).SetString(s); ok {
+		return r
+	}
+	r, _ := f.Rat(nil)
+
+	return r
+}
+
+// add adds a trade of q units to bucket i.
+func (v *VolumeProfile) add(i int64, q *big.Float) {
+	l, ok := v.levels[i]
+	if !ok {
+		l = &profileLevel{volume: new(big.Float).SetPrec(prec).SetMode(mode)}
+		v.levels[i] = l
+	}
+	l.volume.Add(l.volume, q)
+	l.count++
+	v.volume.Add(v.volume, q)
+}
+
+// sub subtracts q units from bucket i, and removes trades of it from the
+// count. Trades are partially evicted with trades set to 0.
+//
+// The bucket is removed once it holds no trades.
+func (v *VolumeProfile) sub(i int64, q *big.Float, trades int) {
+	l := v.levels[i]
+	l.volume.Sub(l.volume, q)
+	l.count -= trades
+	if l.count == 0 {
+		delete(v.levels, i)
+	}
+	v.volume.Sub(v.volume, q)
+}
+
+// Profile returns a snapshot of the histogram.
+func (v *VolumeProfile) Profile() Profile {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+
+	return v.profile()
+}
+
+// profile must be called with v.mu held, or the Calculator's mutex for the
+// profile of its window.
+func (v *VolumeProfile) profile() Profile {
+	is := make([]int64, 0, len(v.levels))
+	for i := range v.levels {
+		is = append(is, i)
+	}
+	sort.Slice(is, func(a, b int) bool { return is[a] < is[b] })
+
+	p := Profile{
+		TickSize: new(big.Float).Set(v.tickSize),
+		Levels:   make([]ProfileLevel, 0, len(is)),
+		Volume:   new(big.Float).Set(v.volume),
+	}
+	if len(is) == 0 {
+		return p
+	}
+
+	poc := 0
+	for j, i := range is {
+		l := v.levels[i]
+		p.Levels = append(p.Levels, ProfileLevel{
+			Price:  v.price(i),
+			Volume: new(big.Float).Set(l.volume),
+			Count:  l.count,
+		})
+		if l.volume.Cmp(p.Levels[poc].Volume) > 0 {
+			poc = j
+		}
+	}
+	p.POC = p.Levels[poc].Price
+
+	low, high := p.valueArea(poc)
+	p.ValueAreaLow = p.Levels[low].Price
+	p.ValueAreaHigh = p.Levels[high].Price
+
+	return p
+}
+
+// price returns the lower bound of bucket i, rounded from its exact value.
+func (v *VolumeProfile) price(i int64) *big.Float {
+	r := new(big.Rat).Mul(new(big.Rat).SetInt64(i), v.tick)
+
+	return new(big.Float).SetPrec(prec).SetMode(mode).SetRat(r)
+}
+
+// valueArea returns the indexes of the lowest and highest Levels of the
+// value area around the level at poc.
+func (p Profile) valueArea(poc int) (low, high int) {
+	var (
+		target = new(big.Float).SetPrec(prec).SetMode(mode).SetFloat64(valueAreaShare)
+		volume = new(big.Float).SetPrec(prec).SetMode(mode).Set(p.Levels[poc].Volume)
+	)
+	target.Mul(target, p.Volume)
+
+	low, high = poc, poc
+	for volume.Cmp(target) < 0 && (low > 0 || high < len(p.Levels)-1) {
+		switch {
+		case low == 0:
+			high++
+			volume.Add(volume, p.Levels[high].Volume)
+		case high == len(p.Levels)-1 || p.Levels[low-1].Volume.Cmp(p.Levels[high+1].Volume) >= 0:
+			low--
+			volume.Add(volume, p.Levels[low].Volume)
+		default:
+			high++
+			volume.Add(volume, p.Levels[high].Volume)
+		}
+	}
+
+	return low, high
+}
+
+// String formats the point of control and the value area.
+func (p Profile) String() string {
+	if p.POC == nil {
+		return "poc=none"
+	}
+
+	return fmt.Sprintf("poc=%s va=[%s, %s] volume=%s levels=%d",
+		p.POC.Text('f', numPrecDigits),
+		p.ValueAreaLow.Text('f', numPrecDigits),
+		p.ValueAreaHigh.Text('f', numPrecDigits),
+		p.Volume.Text('f', numPrecDigits),
+		len(p.Levels),
+	)
+}
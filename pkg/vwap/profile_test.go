@@ -0,0 +1,209 @@
+package vwap
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// levels formats the levels of a profile as price:volume pairs.
+func levels(p Profile) []string {
+	var ls []string
+	for _, l := range p.Levels {
+		ls = append(ls, l.Price.String()+":"+l.Volume.String())
+	}
+	return ls
+}
+
+func TestNewVolumeProfile(t *testing.T) {
+	for _, tick := range []string{"0", "-1"} {
+		v, err := NewVolumeProfile(tick)
+
+		assert.Nil(t, v)
+		assert.ErrorIs(t, err, ErrInvalidTickSize)
+	}
+
+	_, err := NewVolumeProfile("x")
+	assert.ErrorIs(t, err, ErrFloatParse)
+}
+
+func TestVolumeProfile_Update(t *testing.T) {
+	t.Run("Empty", func(t *testing.T) {
+		v, _ := NewVolumeProfile("10")
+
+		p := v.Profile()
+
+		assert.Empty(t, p.Levels)
+		assert.Nil(t, p.POC)
+		assert.Equal(t, "0", p.Volume.String())
+		assert.Equal(t, "poc=none", p.String())
+	})
+
+	t.Run("Point of control and value area", func(t *testing.T) {
+		v, _ := NewVolumeProfile("10")
+		for _, trade := range []Trade{
+			{Price: "101", Size: "1"},
+			{Price: "105", Size: "2"},
+			{Price: "112", Size: "1"},
+			{Price: "95", Size: "0.5"},
+			{Price: "123", Size: "0.2"},
+			{Price: "130", Size: "1"},
+		} {
+			assert.Nil(t, v.Update(trade))
+		}
+
+		p := v.Profile()
+
+		assert.Equal(t, []string{"90:0.5", "100:3", "110:1", "120:0.2", "130:1"}, levels(p))
+		assert.Equal(t, 2, p.Levels[1].Count)
+		assert.Equal(t, "5.7", p.Volume.String())
+		assert.Equal(t, "10", p.TickSize.String())
+		assert.Equal(t, "100", p.POC.String())
+		// 3 + 1 units are 70% of 5.7
+		assert.Equal(t, "100", p.ValueAreaLow.String())
+		assert.Equal(t, "110", p.ValueAreaHigh.String())
+		assert.Equal(t,
+			"poc=100.0000000000000000 va=[100.0000000000000000, 110.0000000000000000] volume=5.7000000000000002 levels=5",
+			p.String(),
+		)
+	})
+
+	t.Run("Value area grows towards more volume", func(t *testing.T) {
+		v, _ := NewVolumeProfile("1")
+		for _, trade := range []Trade{
+			{Price: "1", Size: "2"},
+			{Price: "2", Size: "1"},
+			{Price: "3", Size: "4"},
+			{Price: "4", Size: "1"},
+			{Price: "5", Size: "2"},
+		} {
+			v.Update(trade)
+		}
+
+		p := v.Profile()
+
+		// 4 units, then 1 on a tie towards the lower level, then 2 units
+		// rather than 1
+		assert.Equal(t, "3", p.POC.String())
+		assert.Equal(t, "1", p.ValueAreaLow.String())
+		assert.Equal(t, "3", p.ValueAreaHigh.String())
+	})
+
+	t.Run("Cents", func(t *testing.T) {
+		v, _ := NewVolumeProfile("0.01")
+		v.Update(Trade{Price: "22386.79", Size: "1"})
+		v.Update(Trade{Price: "22386.795", Size: "1"})
+
+		p := v.Profile()
+
+		assert.Len(t, p.Levels, 1)
+		assert.Equal(t, "22386.79", p.POC.Text('f', 2))
+	})
+
+	t.Run("Prices on tick boundaries", func(t *testing.T) {
+		tests := []struct {
+			tick, price, exp string
+		}{
+			{"0.1", "0.3", "0.3"},
+			{"0.1", "0.7", "0.7"},
+			{"0.01", "1.15", "1.15"},
+			{"0.01", "22386.79", "22386.79"},
+			{"0.1", "0.29999", "0.2"},
+		}
+
+		for _, tc := range tests {
+			v, _ := NewVolumeProfile(tc.tick)
+			assert.Nil(t, v.Update(Trade{Price: tc.price, Size: "1"}))
+
+			assert.Equal(t, tc.exp, v.Profile().POC.String(), "%s at tick %s", tc.price, tc.tick)
+		}
+	})
+
+	t.Run("Price out of range", func(t *testing.T) {
+		v, _ := NewVolumeProfile("1e-18")
+
+		// 10 ticks away from the largest int64
+		assert.Nil(t, v.Update(Trade{Price: "9.223372036854775797", Size: "1"}))
+		assert.ErrorIs(t, v.Update(Trade{Price: "100", Size: "1"}), ErrPriceOutOfRange)
+		assert.Len(t, v.Profile().Levels, 1)
+	})
+
+	t.Run("Invalid trade", func(t *testing.T) {
+		v, _ := NewVolumeProfile("1")
+
+		assert.ErrorIs(t, v.Update(Trade{Price: "0", Size: "1"}), ErrNonPositivePrice)
+		assert.Empty(t, v.Profile().Levels)
+	})
+}
+
+func TestWithVolumeProfile(t *testing.T) {
+	t.Run("Disabled", func(t *testing.T) {
+		calc, _ := NewCalculator(1)
+		calc.Update(Trade{Price: "1", Size: "1"})
+
+		assert.Equal(t, Profile{}, calc.VolumeProfile())
+	})
+
+	t.Run("Invalid tick size", func(t *testing.T) {
+		calc, err := NewCalculator(1, WithVolumeProfile("0"))
+
+		assert.Nil(t, calc)
+		assert.ErrorIs(t, err, ErrInvalidTickSize)
+	})
+
+	t.Run("Sliding window", func(t *testing.T) {
+		calc, _ := NewCalculator(2, WithVolumeProfile("1"))
+
+		calc.Update(Trade{Price: "10.5", Size: "1"})
+		calc.Update(Trade{Price: "11.2", Size: "2"})
+		p := calc.VolumeProfile()
+		assert.Equal(t, []string{"10:1", "11:2"}, levels(p))
+		assert.Equal(t, "11", p.POC.String())
+
+		calc.Update(Trade{Price: "12", Size: "1"})
+		p = calc.VolumeProfile()
+		assert.Equal(t, []string{"11:2", "12:1"}, levels(p))
+		assert.Equal(t, "3", p.Volume.String())
+
+		calc.Update(Trade{Price: "12.9", Size: "1"})
+		p = calc.VolumeProfile()
+		assert.Equal(t, []string{"12:2"}, levels(p))
+		assert.Equal(t, 2, p.Levels[0].Count)
+	})
+
+	t.Run("Volume window", func(t *testing.T) {
+		calc, _ := NewCalculator(10, WithVolumeWindow("2"), WithVolumeProfile("1"))
+
+		calc.Update(Trade{Price: "10", Size: "2"})
+		calc.Update(Trade{Price: "11", Size: "1"})
+
+		p := calc.VolumeProfile()
+		assert.Equal(t, []string{"10:1", "11:1"}, levels(p))
+		assert.Equal(t, 1, p.Levels[0].Count)
+		assert.Equal(t, "10", p.POC.String())
+	})
+
+	t.Run("Price out of range", func(t *testing.T) {
+		calc, _ := NewCalculator(2, WithVolumeProfile("1e-18"))
+		calc.Update(Trade{Price: "1", Size: "1"})
+
+		_, err := calc.Update(Trade{Price: "100", Size: "1"})
+		assert.ErrorIs(t, err, ErrPriceOutOfRange)
+		assert.Equal(t, 1, calc.Value().Count)
+		assert.Len(t, calc.VolumeProfile().Levels, 1)
+	})
+
+	t.Run("Prices on tick boundaries", func(t *testing.T) {
+		calc, _ := NewCalculator(2, WithVolumeProfile("0.1"))
+
+		calc.Update(Trade{Price: "0.3", Size: "1"})
+		calc.Update(Trade{Price: "0.7", Size: "1"})
+		assert.Equal(t, []string{"0.3:1", "0.7:1"}, levels(calc.VolumeProfile()))
+
+		// Restored trades land in the same buckets
+		b, _ := calc.MarshalBinary()
+		restored, _ := NewCalculator(2, WithVolumeProfile("0.1"))
+		assert.Nil(t, restored.UnmarshalBinary(b))
+		assert.Equal(t, []string{"0.3:1", "0.7:1"}, levels(restored.VolumeProfile()))
+	})
+}
@@ -0,0 +1,104 @@
+package vwap
+
+import (
+	"math/big"
+)
+
+// Side is the side of the aggressor (taker) order of a trade.
+type Side uint8
+
+const (
+	// SideUnknown is the zero value, for trades whose side is not known.
+	SideUnknown Side = iota
+	// Buy means the taker bought, lifting a resting sell order.
+	Buy
+	// Sell means the taker sold, hitting a resting buy order.
+	Sell
+)
+
+func (s Side) String() string {
+	switch s {
+	case Buy:
+		return "buy"
+	case Sell:
+		return "sell"
+	default:
+		return "unknown"
+	}
+}
+
+// SideResult is the VWAP of the trades of a single aggressor side in the
+// window.
+type SideResult struct {
+	// VWAP is zero if the window holds no trades of this side.
+	VWAP   *big.Float
+	Volume *big.Float
+	Count  int
+}
+
+// sideTotals holds the running sums of the trades of a single aggressor
+// side.
+type sideTotals struct {
+	cumulativeTypicalPrice *big.Float
+	cumulativeVolume       *big.Float
+	count                  int
+}
+
+func newSideTotals() *sideTotals {
+	return &sideTotals{
+		cumulativeTypicalPrice: new(big.Float).SetPrec(prec).SetMode(mode),
+		cumulativeVolume:       new(big.Float).SetPrec(prec).SetMode(mode),
+	}
+}
+
+func (s *sideTotals) add(pq, q *big.Float) {
+	s.cumulativeTypicalPrice.Add(s.cumulativeTypicalPrice, pq)
+	s.cumulativeVolume.Add(s.cumulativeVolume, q)
+	s.count++
+}
+
+func (s *sideTotals) sub(pq, q *big.Float) {
+	s.cumulativeTypicalPrice.Sub(s.cumulativeTypicalPrice, pq)
+	s.cumulativeVolume.Sub(s.cumulativeVolume, q)
+	s.count--
+}
+
+// shrink subtracts part of a trade that stays in the window.
+func (s *sideTotals) shrink(pq, q *big.Float) {
+	s.cumulativeTypicalPrice.Sub(s.cumulativeTypicalPrice, pq)
+	s.cumulativeVolume.Sub(s.cumulativeVolume, q)
+}
+
+func (s *sideTotals) result() *SideResult {
+	r := &SideResult{
+		VWAP:   new(big.Float).SetPrec(prec).SetMode(mode),
+		Volume: new(big.Float).Set(s.cumulativeVolume),
+		Count:  s.count,
+	}
+	// The rolling sums may not reach exactly zero, so emptiness is decided
+	// by the number of trades.
+	if s.count > 0 {
+		r.VWAP.Quo(s.cumulativeTypicalPrice, s.cumulativeVolume)
+	} else {
+		r.Volume.SetInt64(0)
+	}
+
+	return r
+}
+
+// imbalance returns the net order-flow imbalance of the window:
+//
+//	(buy volume - sell volume) / (buy volume + sell volume)
+//
+// which ranges from -1 (only sellers) to 1 (only buyers). It is zero for an
+// empty window.
+func imbalance(buy, sell *SideResult) *big.Float {
+	v := new(big.Float).SetPrec(prec).SetMode(mode)
+	total := new(big.Float).SetPrec(prec).SetMode(mode).Add(buy.Volume, sell.Volume)
+	if total.Sign() == 0 {
+		return v
+	}
+	v.Sub(buy.Volume, sell.Volume)
+
+	return v.Quo(v, total)
+}
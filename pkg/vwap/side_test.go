@@ -0,0 +1,79 @@
+package vwap
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestWithSideSplit(t *testing.T) {
+	t.Run("Disabled", func(t *testing.T) {
+		calc, _ := NewCalculator(1)
+
+		r, err := calc.Update(Trade{Price: "1", Size: "1"})
+
+		assert.Nil(t, err)
+		assert.Nil(t, r.Buy)
+		assert.Nil(t, r.Sell)
+		assert.Nil(t, r.Imbalance)
+	})
+
+	t.Run("Unknown side", func(t *testing.T) {
+		calc, _ := NewCalculator(1, WithSideSplit())
+
+		_, err := calc.Update(Trade{Price: "1", Size: "1"})
+
+		assert.ErrorIs(t, err, ErrUnknownSide)
+		assert.Equal(t, 0, calc.Value().Count)
+	})
+
+	t.Run("Empty window", func(t *testing.T) {
+		calc, _ := NewCalculator(1, WithSideSplit())
+
+		r := calc.Value()
+
+		assert.Equal(t, 0, r.Buy.VWAP.Sign())
+		assert.Equal(t, 0, r.Sell.VWAP.Sign())
+		assert.Equal(t, 0, r.Imbalance.Sign())
+	})
+
+	t.Run("Split by side", func(t *testing.T) {
+		calc, _ := NewCalculator(3, WithSideSplit())
+
+		r, _ := calc.Update(Trade{Price: "10", Size: "1", Side: Buy})
+		assert.Equal(t, "10", r.Buy.VWAP.String())
+		assert.Equal(t, 1, r.Buy.Count)
+		assert.Equal(t, 0, r.Sell.Count)
+		assert.Equal(t, 0, r.Sell.VWAP.Sign())
+		assert.Equal(t, "1", r.Imbalance.String())
+
+		calc.Update(Trade{Price: "20", Size: "3", Side: Buy})
+		r, _ = calc.Update(Trade{Price: "8", Size: "4", Side: Sell})
+		// buy VWAP: 70/4, sell VWAP: 32/4
+		assert.Equal(t, "17.5", r.Buy.VWAP.String())
+		assert.Equal(t, "4", r.Buy.Volume.String())
+		assert.Equal(t, 2, r.Buy.Count)
+		assert.Equal(t, "8", r.Sell.VWAP.String())
+		assert.Equal(t, "4", r.Sell.Volume.String())
+		assert.Equal(t, 1, r.Sell.Count)
+		assert.Equal(t, 0, r.Imbalance.Sign())
+
+		// Slides out the first buy trade
+		r, _ = calc.Update(Trade{Price: "6", Size: "1", Side: Sell})
+		assert.Equal(t, "20", r.Buy.VWAP.String())
+		assert.Equal(t, "3", r.Buy.Volume.String())
+		assert.Equal(t, 1, r.Buy.Count)
+		assert.Equal(t, "7.6", r.Sell.VWAP.String())
+		assert.Equal(t, "5", r.Sell.Volume.String())
+		assert.Equal(t, 2, r.Sell.Count)
+		assert.Equal(t, "-0.25", r.Imbalance.String())
+
+		// Slides out all buy trades
+		calc.Update(Trade{Price: "6", Size: "1", Side: Sell})
+		r, _ = calc.Update(Trade{Price: "6", Size: "1", Side: Sell})
+		assert.Equal(t, 0, r.Buy.Count)
+		assert.Equal(t, 0, r.Buy.VWAP.Sign())
+		assert.Equal(t, 0, r.Buy.Volume.Sign())
+		assert.Equal(t, "-1", r.Imbalance.String())
+	})
+}
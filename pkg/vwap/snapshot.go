@@ -0,0 +1,371 @@
+package vwap
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"math/big"
+	"time"
+)
+
+// Snapshots are encoded as:
+//
+//	magic    [4]byte "VWAP"
+//	version  uint8
+//	flags    uint8    snapshotBands | snapshotSides
+//	count    uvarint  number of trades
+//	newest   bytes    time of the last trade received
+//	trades   [count]  oldest first, each one made of
+//	  p      bytes    Price
+//	  pq     bytes    Price x Quantity
+//	  q      bytes    Quantity
+//	  p2q    bytes    Price² x Quantity, if snapshotBands
+//	  side   uint8    if snapshotSides
+//	  time   bytes
+//	  id     varint   TradeID
+//
+// where bytes is a uvarint length followed by a big.Float or time.Time
+// binary encoding.
+//
+// Version 1 snapshots lacked the prices and trade IDs, and are not supported.
+const (
+	snapshotMagic   = "VWAP"
+	snapshotVersion = 2
+	// minSnapshotTradeSize is the size of the shortest encoded trade: the
+	// length prefixes of its p, pq, q and time and its id, at least one byte
+	// each.
+	minSnapshotTradeSize = 5
+)
+
+// Snapshot flags.
+const (
+	snapshotBands = 1 << iota
+	snapshotSides
+)
+
+var (
+	// ErrInvalidSnapshot is returned by UnmarshalBinary for data that is
+	// not a snapshot of a supported version.
+	ErrInvalidSnapshot = errors.New("invalid snapshot")
+
+	// ErrSnapshotMismatch is returned by UnmarshalBinary for snapshots of a
+	// Calculator with a different set of options.
+	ErrSnapshotMismatch = errors.New("snapshot does not match calculator options")
+)
+
+// MarshalBinary implements encoding.BinaryMarshaler. It encodes the trades
+// in the window, which can be restored with UnmarshalBinary.
+func (c *Calculator) MarshalBinary() ([]byte, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	var buf bytes.Buffer
+	buf.WriteString(snapshotMagic)
+	buf.WriteByte(snapshotVersion)
+	buf.WriteByte(c.snapshotFlags())
+
+	w := c.trades
+	writeUvarint(&buf, uint64(w.Len()))
+	if err := writeTime(&buf, c.newest); err != nil {
+		return nil, err
+	}
+	for j := 0; j < w.Len(); j++ {
+		i := w.slot(j)
+		if err := writeFloat(&buf, &w.ps[i]); err != nil {
+			return nil, err
+		}
+		if err := writeFloat(&buf, &w.pqs[i]); err != nil {
+			return nil, err
+		}
+		if err := writeFloat(&buf, &w.qs[i]); err != nil {
+			return nil, err
+		}
+		if c.cumulativeSquaredTypicalPrice != nil {
+			if err := writeFloat(&buf, &w.p2qs[i]); err != nil {
+				return nil, err
+			}
+		}
+		if c.buys != nil {
+			buf.WriteByte(byte(w.sides[i]))
+		}
+		if err := writeTime(&buf, w.ts[i]); err != nil {
+			return nil, err
+		}
+		writeVarint(&buf, int64(w.ids[i]))
+	}
+
+	return buf.Bytes(), nil
+}
+
+// UnmarshalBinary implements encoding.BinaryUnmarshaler. It replaces the
+// window with the trades of a snapshot created by MarshalBinary.
+//
+// The Calculator must have been created with the same WithBands and
+// WithSideSplit options as the one that created the snapshot, otherwise
+// ErrSnapshotMismatch is returned. If the snapshot holds more trades than
+// the window width, only the newest ones are restored, and trades exceeding
+// the maximum age or volume of the Calculator are discarded as on Update.
+func (c *Calculator) UnmarshalBinary(data []byte) error {
+	r := bytes.NewReader(data)
+
+	header := make([]byte, len(snapshotMagic)+2)
+	if _, err := io.ReadFull(r, header); err != nil || string(header[:len(snapshotMagic)]) != snapshotMagic {
+		return fmt.Errorf("%w: bad header", ErrInvalidSnapshot)
+	}
+	if v := header[len(snapshotMagic)]; v != snapshotVersion {
+		return fmt.Errorf("%w: unsupported version %d, expected %d", ErrInvalidSnapshot, v, snapshotVersion)
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if flags := header[len(snapshotMagic)+1]; flags != c.snapshotFlags() {
+		return fmt.Errorf("%w: flags %b, expected %b", ErrSnapshotMismatch, flags, c.snapshotFlags())
+	}
+
+	n, err := binary.ReadUvarint(r)
+	if err != nil {
+		return fmt.Errorf("%w: %v", ErrInvalidSnapshot, err)
+	}
+	// The count is checked against the data left before allocating for it.
+	if n > uint64(r.Len()/minSnapshotTradeSize) {
+		return fmt.Errorf("%w: %d trades in %d bytes", ErrInvalidSnapshot, n, r.Len())
+	}
+	var newest time.Time
+	if err := readTime(r, &newest); err != nil {
+		return err
+	}
+
+	// Decode everything before touching the window, so that an invalid
+	// snapshot leaves it untouched.
+	trades := make([]snapshotTrade, 0, n)
+	for i := uint64(0); i < n; i++ {
+		t, err := readSnapshotTrade(r, c.snapshotFlags())
+		if err != nil {
+			return err
+		}
+		if c.profile != nil {
+			// The shortest decimal that rounds to p is the price it was
+			// parsed from, unless that had more digits than a float64
+			// holds.
+			if t.bucket, err = c.profile.bucket(t.p.Text('g', -1), t.p); err != nil {
+				return fmt.Errorf("%w: %v", ErrInvalidSnapshot, err)
+			}
+		}
+		trades = append(trades, t)
+	}
+	if len(trades) > c.windowWidth {
+		trades = trades[len(trades)-c.windowWidth:]
+	}
+
+	c.reset()
+	for _, t := range trades {
+		c.restore(t)
+	}
+	c.newest = newest
+	// The snapshot may have been taken WithMaxAge or WithVolumeWindow
+	// allowing more than c does.
+	c.evictOverBudget()
+	c.updateVWAP()
+
+	return nil
+}
+
+// snapshotTrade is a trade of the window as stored in a snapshot.
+type snapshotTrade struct {
+	p, pq, q, p2q *big.Float
+	side          Side
+	time          time.Time
+	id            int
+	// bucket is the volume profile bucket of the trade, if the volume
+	// profile is enabled.
+	bucket int64
+}
+
+func readSnapshotTrade(r *bytes.Reader, flags byte) (snapshotTrade, error) {
+	t := snapshotTrade{p: new(big.Float), pq: new(big.Float), q: new(big.Float)}
+	if err := readFloat(r, t.p); err != nil {
+		return t, err
+	}
+	if err := readFloat(r, t.pq); err != nil {
+		return t, err
+	}
+	if err := readFloat(r, t.q); err != nil {
+		return t, err
+	}
+	if t.p.Sign() <= 0 || t.pq.Sign() <= 0 || t.q.Sign() <= 0 || t.p.IsInf() || t.pq.IsInf() || t.q.IsInf() {
+		return t, fmt.Errorf("%w: trade out of range", ErrInvalidSnapshot)
+	}
+	if flags&snapshotBands != 0 {
+		t.p2q = new(big.Float)
+		if err := readFloat(r, t.p2q); err != nil {
+			return t, err
+		}
+		if t.p2q.Sign() <= 0 || t.p2q.IsInf() {
+			return t, fmt.Errorf("%w: trade out of range", ErrInvalidSnapshot)
+		}
+	}
+	if flags&snapshotSides != 0 {
+		b, err := r.ReadByte()
+		if err != nil {
+			return t, fmt.Errorf("%w: %v", ErrInvalidSnapshot, err)
+		}
+		t.side = Side(b)
+		if t.side != Buy && t.side != Sell {
+			return t, fmt.Errorf("%w: %v", ErrInvalidSnapshot, ErrUnknownSide)
+		}
+	}
+	if err := readTime(r, &t.time); err != nil {
+		return t, err
+	}
+	id, err := binary.ReadVarint(r)
+	if err != nil {
+		return t, fmt.Errorf("%w: %v", ErrInvalidSnapshot, err)
+	}
+	t.id = int(id)
+
+	return t, nil
+}
+
+// snapshotFlags returns the options of c that change the snapshot encoding.
+func (c *Calculator) snapshotFlags() byte {
+	var flags byte
+	if c.cumulativeSquaredTypicalPrice != nil {
+		flags |= snapshotBands
+	}
+	if c.buys != nil {
+		flags |= snapshotSides
+	}
+
+	return flags
+}
+
+// reset empties the window. It must be called with c.mu held.
+func (c *Calculator) reset() {
+	for c.trades.Len() > 0 {
+		c.evictOldest()
+	}
+	c.trades.reset()
+	c.cumulativeTypicalPrice.SetInt64(0)
+	c.cumulativeVolume.SetInt64(0)
+	if c.cumulativeSquaredTypicalPrice != nil {
+		c.cumulativeSquaredTypicalPrice.SetInt64(0)
+	}
+	if c.buys != nil {
+		c.buys, c.sells = newSideTotals(), newSideTotals()
+	}
+}
+
+// restore appends a trade of a snapshot to the window. It must be called
+// with c.mu held and room in the window.
+func (c *Calculator) restore(t snapshotTrade) {
+	w := c.trades
+	i := w.push()
+	pq := w.pqs[i].SetPrec(prec).SetMode(mode).Set(t.pq)
+	q := w.qs[i].SetPrec(prec).SetMode(mode).Set(t.q)
+	p := new(big.Float).SetPrec(prec).SetMode(mode).Set(t.p)
+	w.ps[i].Set(p)
+	w.sides[i] = t.side
+	w.ts[i] = t.time
+	w.ids[i] = t.id
+
+	c.cumulativeTypicalPrice.Add(c.cumulativeTypicalPrice, pq)
+	c.cumulativeVolume.Add(c.cumulativeVolume, q)
+	if c.cumulativeSquaredTypicalPrice != nil {
+		p2q := w.p2qs[i].SetPrec(prec).SetMode(mode).Set(t.p2q)
+		c.cumulativeSquaredTypicalPrice.Add(c.cumulativeSquaredTypicalPrice, p2q)
+	}
+	if c.buys != nil {
+		c.sideTotals(t.side).add(pq, q)
+	}
+	if c.prices != nil {
+		c.prices.add(p, q)
+	}
+	if c.profile != nil {
+		w.buckets[i] = t.bucket
+		c.profile.add(w.buckets[i], q)
+	}
+	if c.highs != nil {
+		c.highs.Push(p)
+		c.lows.Push(p)
+	}
+}
+
+func writeUvarint(buf *bytes.Buffer, v uint64) {
+	var b [binary.MaxVarintLen64]byte
+	buf.Write(b[:binary.PutUvarint(b[:], v)])
+}
+
+func writeVarint(buf *bytes.Buffer, v int64) {
+	var b [binary.MaxVarintLen64]byte
+	buf.Write(b[:binary.PutVarint(b[:], v)])
+}
+
+func writeFloat(buf *bytes.Buffer, f *big.Float) error {
+	b, err := f.GobEncode()
+	if err != nil {
+		return err
+	}
+	writeBytes(buf, b)
+
+	return nil
+}
+
+func writeTime(buf *bytes.Buffer, t time.Time) error {
+	b, err := t.MarshalBinary()
+	if err != nil {
+		return err
+	}
+	writeBytes(buf, b)
+
+	return nil
+}
+
+// writeBytes writes b prefixed by its length.
+func writeBytes(buf *bytes.Buffer, b []byte) {
+	writeUvarint(buf, uint64(len(b)))
+	buf.Write(b)
+}
+
+func readFloat(r *bytes.Reader, f *big.Float) error {
+	b, err := readBytes(r)
+	if err != nil {
+		return err
+	}
+	if err := f.GobDecode(b); err != nil {
+		return fmt.Errorf("%w: %v", ErrInvalidSnapshot, err)
+	}
+
+	return nil
+}
+
+func readTime(r *bytes.Reader, t *time.Time) error {
+	b, err := readBytes(r)
+	if err != nil {
+		return err
+	}
+	if err := t.UnmarshalBinary(b); err != nil {
+		return fmt.Errorf("%w: %v", ErrInvalidSnapshot, err)
+	}
+
+	return nil
+}
+
+// readBytes reads a length-prefixed byte slice.
+func readBytes(r *bytes.Reader) ([]byte, error) {
+	n, err := binary.ReadUvarint(r)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidSnapshot, err)
+	}
+	if n > uint64(r.Len()) {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidSnapshot, io.ErrUnexpectedEOF)
+	}
+	b := make([]byte, n)
+	if _, err := io.ReadFull(r, b); err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidSnapshot, err)
+	}
+
+	return b, nil
+}
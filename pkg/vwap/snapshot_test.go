@@ -0,0 +1,196 @@
+package vwap
+
+import (
+	"bytes"
+	"math"
+	"math/big"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCalculator_MarshalBinary(t *testing.T) {
+	var (
+		t0     = time.Date(2022, 9, 12, 14, 28, 52, 0, time.UTC)
+		trades = []Trade{
+			{Price: "22386.79", Size: "0.0006", Time: t0, Side: Buy, TradeID: 1},
+			{Price: "22386.8", Size: "0.01", Time: t0.Add(time.Second), Side: Sell, TradeID: 2},
+			{Price: "22390.01", Size: "1.5", Time: t0.Add(2 * time.Second), Side: Buy, TradeID: 3},
+			{Price: "22389.5", Size: "0.25", Time: t0.Add(3 * time.Second), Side: Sell, TradeID: 4},
+		}
+	)
+
+	t.Run("Round trip", func(t *testing.T) {
+		tests := []struct {
+			name string
+			opts []Option
+		}{
+			{"No options", nil},
+			{"Bands", []Option{WithBands(1, 2)}},
+			{"Sides", []Option{WithSideSplit()}},
+			{"Bands and sides", []Option{WithBands(1), WithSideSplit()}},
+			{"Partially discarded trade", []Option{WithVolumeWindow("1.6")}},
+			{"Percentiles", []Option{WithPercentiles(5, 50, 95)}},
+			{"Percentiles of a partially discarded trade", []Option{WithVolumeWindow("1.6"), WithPercentiles(0, 50)}},
+			{"Volume profile", []Option{WithVolumeWindow("1.6"), WithVolumeProfile("0.5")}},
+			{"Range of a partially discarded trade", []Option{WithVolumeWindow("1.6"), WithRange()}},
+		}
+
+		for _, tc := range tests {
+			t.Run(tc.name, func(t *testing.T) {
+				calc, _ := NewCalculator(3, tc.opts...)
+				for _, trade := range trades {
+					calc.Update(trade)
+				}
+
+				b, err := calc.MarshalBinary()
+				assert.Nil(t, err)
+
+				restored, _ := NewCalculator(3, tc.opts...)
+				assert.Nil(t, restored.UnmarshalBinary(b))
+				assert.Equal(t, calc.Value(), restored.Value())
+				assert.Equal(t, calc.VolumeProfile(), restored.VolumeProfile())
+
+				// Both calculators keep sliding the same way
+				next := Trade{Price: "22391", Size: "2", Time: t0.Add(4 * time.Second), Side: Buy}
+				exp, _ := calc.Update(next)
+				act, _ := restored.Update(next)
+				assert.Equal(t, exp, act)
+			})
+		}
+	})
+
+	t.Run("Prices and trade IDs", func(t *testing.T) {
+		calc, _ := NewCalculator(3)
+		// 0.1 x 3 / 3 is not 0.1 in binary
+		calc.Update(Trade{Price: "0.1", Size: "3", TradeID: 7})
+		b, _ := calc.MarshalBinary()
+
+		restored, _ := NewCalculator(3)
+		assert.Nil(t, restored.UnmarshalBinary(b))
+
+		w := restored.trades
+		assert.Equal(t, "0.1", w.ps[w.front()].String())
+		assert.Equal(t, 0, w.ps[w.front()].Cmp(&calc.trades.ps[0]))
+		assert.Equal(t, 7, w.ids[w.front()])
+	})
+
+	t.Run("Empty window", func(t *testing.T) {
+		calc, _ := NewCalculator(3)
+
+		b, _ := calc.MarshalBinary()
+		restored, _ := NewCalculator(3)
+		restored.Update(trades[0])
+
+		assert.Nil(t, restored.UnmarshalBinary(b))
+		assert.Equal(t, 0, restored.Value().Count)
+	})
+
+	t.Run("Narrower window keeps the newest trades", func(t *testing.T) {
+		calc, _ := NewCalculator(4)
+		for _, trade := range trades {
+			calc.Update(trade)
+		}
+		b, _ := calc.MarshalBinary()
+
+		restored, _ := NewCalculator(2)
+		assert.Nil(t, restored.UnmarshalBinary(b))
+
+		exp, _ := NewCalculator(2)
+		for _, trade := range trades {
+			exp.Update(trade)
+		}
+		assert.Equal(t, exp.Value(), restored.Value())
+	})
+
+	t.Run("Smaller budgets discard trades", func(t *testing.T) {
+		tests := []struct {
+			name string
+			opts []Option
+		}{
+			{"Volume window", []Option{WithVolumeWindow("1")}},
+			{"Maximum age", []Option{WithMaxAge(time.Second)}},
+		}
+
+		for _, tc := range tests {
+			t.Run(tc.name, func(t *testing.T) {
+				calc, _ := NewCalculator(4, WithVolumeWindow("10"), WithMaxAge(time.Minute))
+				for _, trade := range trades {
+					calc.Update(trade)
+				}
+				b, _ := calc.MarshalBinary()
+
+				restored, _ := NewCalculator(4, tc.opts...)
+				assert.Nil(t, restored.UnmarshalBinary(b))
+
+				exp, _ := NewCalculator(4, tc.opts...)
+				for _, trade := range trades {
+					exp.Update(trade)
+				}
+				assert.Equal(t, exp.Value(), restored.Value())
+			})
+		}
+	})
+
+	t.Run("Out of range Price² x Quantity", func(t *testing.T) {
+		for _, p2q := range []*big.Float{big.NewFloat(-1), new(big.Float).SetInf(false)} {
+			var buf bytes.Buffer
+			buf.WriteString(snapshotMagic)
+			buf.Write([]byte{snapshotVersion, snapshotBands})
+			writeUvarint(&buf, 1)
+			assert.Nil(t, writeTime(&buf, t0))
+			for _, f := range []*big.Float{big.NewFloat(2), big.NewFloat(2), big.NewFloat(1), p2q} {
+				assert.Nil(t, writeFloat(&buf, f))
+			}
+			assert.Nil(t, writeTime(&buf, t0))
+			writeVarint(&buf, 1)
+
+			restored, _ := NewCalculator(3, WithBands(1))
+			assert.ErrorIs(t, restored.UnmarshalBinary(buf.Bytes()), ErrInvalidSnapshot, p2q.String())
+			assert.Equal(t, 0, restored.Value().Count)
+		}
+	})
+
+	t.Run("Options mismatch", func(t *testing.T) {
+		calc, _ := NewCalculator(3, WithBands(1))
+		calc.Update(trades[0])
+		b, _ := calc.MarshalBinary()
+
+		restored, _ := NewCalculator(3, WithSideSplit())
+		restored.Update(trades[1])
+
+		assert.ErrorIs(t, restored.UnmarshalBinary(b), ErrSnapshotMismatch)
+		assert.Equal(t, 1, restored.Value().Count)
+	})
+
+	t.Run("Invalid snapshots", func(t *testing.T) {
+		calc, _ := NewCalculator(3)
+		for _, trade := range trades {
+			calc.Update(trade)
+		}
+		b, _ := calc.MarshalBinary()
+
+		// A header claiming the maximum count of trades
+		var corrupt bytes.Buffer
+		corrupt.WriteString(snapshotMagic)
+		corrupt.Write([]byte{snapshotVersion, 0})
+		writeUvarint(&corrupt, math.MaxUint64)
+		assert.Nil(t, writeTime(&corrupt, t0))
+
+		tests := map[string][]byte{
+			"Empty":         {},
+			"Bad magic":     append([]byte("XWAP"), b[4:]...),
+			"Version":       append(append([]byte(snapshotMagic), snapshotVersion+1), b[5:]...),
+			"Truncated":     b[:len(b)-1],
+			"Corrupt count": corrupt.Bytes(),
+		}
+		for name, data := range tests {
+			restored, _ := NewCalculator(3)
+			restored.Update(trades[0])
+
+			assert.ErrorIs(t, restored.UnmarshalBinary(data), ErrInvalidSnapshot, name)
+			assert.Equal(t, 1, restored.Value().Count, name)
+		}
+	})
+}
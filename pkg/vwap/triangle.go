@@ -0,0 +1,118 @@
+package vwap
+
+import (
+	"errors"
+	"fmt"
+	"math/big"
+	"strings"
+	"sync"
+)
+
+// ErrInvalidTriangle is returned by NewTriangle for products that are not
+// of the BASE-QUOTE form or that do not form a triangle.
+var ErrInvalidTriangle = errors.New("invalid triangle")
+
+// bpsPerUnit is the number of basis points in a unit.
+const bpsPerUnit = 10_000
+
+// Spread compares the VWAP of a directly traded cross rate with the one
+// implied by the other two legs of its Triangle.
+type Spread struct {
+	Direct  *big.Float
+	Implied *big.Float
+	// Bps is the spread of Direct over Implied in basis points of Implied.
+	Bps *big.Float
+}
+
+func (s Spread) String() string {
+	return fmt.Sprintf("direct=%s implied=%s spread=%.2fbps",
+		s.Direct.Text('f', numPrecDigits),
+		s.Implied.Text('f', numPrecDigits),
+		s.Bps,
+	)
+}
+
+// Triangle monitors the VWAPs of the three legs of a currency triangle,
+// e.g. ETH-BTC, ETH-USD and BTC-USD, where the cross rate ETH-BTC is also
+// implied by the other two legs: ETH-USD / BTC-USD.
+type Triangle struct {
+	mu sync.Mutex
+
+	// Cross is the directly traded product, and Numerator and Denominator
+	// the legs implying it.
+	Cross       string
+	Numerator   string
+	Denominator string
+
+	// vwaps holds the last VWAP of every leg, by product.
+	vwaps map[string]*big.Float
+}
+
+// NewTriangle returns the Triangle of the cross product, e.g. "ETH-BTC",
+// traded against the via currency, e.g. "USD". Its legs are then ETH-USD
+// and BTC-USD.
+func NewTriangle(cross, via string) (*Triangle, error) {
+	base, quote, ok := strings.Cut(cross, "-")
+	if !ok || base == "" || quote == "" || strings.Contains(quote, "-") {
+		return nil, fmt.Errorf("%w: product %q is not BASE-QUOTE", ErrInvalidTriangle, cross)
+	}
+	if via == "" || via == base || via == quote || strings.Contains(via, "-") {
+		return nil, fmt.Errorf("%w: %s via %q", ErrInvalidTriangle, cross, via)
+	}
+
+	return &Triangle{
+		Cross:       cross,
+		Numerator:   base + "-" + via,
+		Denominator: quote + "-" + via,
+		vwaps:       make(map[string]*big.Float),
+	}, nil
+}
+
+// String names the triangle by its cross product and via currency, e.g.
+// "ETH-BTC/USD".
+func (t *Triangle) String() string {
+	_, via, _ := strings.Cut(t.Numerator, "-")
+
+	return t.Cross + "/" + via
+}
+
+// Legs returns the products of the triangle.
+func (t *Triangle) Legs() []string {
+	return []string{t.Cross, t.Numerator, t.Denominator}
+}
+
+// Update records the VWAP of a product and returns the spread if the
+// product is a leg of the triangle and every leg has a positive VWAP.
+func (t *Triangle) Update(product string, vwap *big.Float) (Spread, bool) {
+	if product != t.Cross && product != t.Numerator && product != t.Denominator {
+		return Spread{}, false
+	}
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	t.vwaps[product] = new(big.Float).SetPrec(prec).SetMode(mode).Set(vwap)
+
+	var (
+		direct      = t.vwaps[t.Cross]
+		numerator   = t.vwaps[t.Numerator]
+		denominator = t.vwaps[t.Denominator]
+	)
+	// An empty window has a zero VWAP.
+	if direct == nil || direct.Sign() <= 0 ||
+		numerator == nil || numerator.Sign() <= 0 ||
+		denominator == nil || denominator.Sign() <= 0 {
+		return Spread{}, false
+	}
+
+	s := Spread{
+		Direct:  new(big.Float).Set(direct),
+		Implied: new(big.Float).SetPrec(prec).SetMode(mode).Quo(numerator, denominator),
+		Bps:     new(big.Float).SetPrec(prec).SetMode(mode),
+	}
+	s.Bps.Sub(s.Direct, s.Implied)
+	s.Bps.Quo(s.Bps, s.Implied)
+	s.Bps.Mul(s.Bps, big.NewFloat(bpsPerUnit))
+
+	return s, true
+}
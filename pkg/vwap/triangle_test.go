@@ -0,0 +1,61 @@
+package vwap
+
+import (
+	"math/big"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNewTriangle(t *testing.T) {
+	tri, err := NewTriangle("ETH-BTC", "USD")
+
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"ETH-BTC", "ETH-USD", "BTC-USD"}, tri.Legs())
+	assert.Equal(t, "ETH-BTC/USD", tri.String())
+
+	invalid := []struct{ cross, via string }{
+		{"ETHBTC", "USD"},
+		{"ETH-", "USD"},
+		{"ETH-BTC-USD", "EUR"},
+		{"ETH-BTC", ""},
+		{"ETH-BTC", "BTC"},
+		{"ETH-BTC", "USD-EUR"},
+	}
+	for _, tt := range invalid {
+		tri, err := NewTriangle(tt.cross, tt.via)
+
+		assert.Nil(t, tri)
+		assert.ErrorIs(t, err, ErrInvalidTriangle, tt)
+	}
+}
+
+func TestTriangle_Update(t *testing.T) {
+	tri, _ := NewTriangle("ETH-BTC", "USD")
+	update := func(product, vwap string) (Spread, bool) {
+		v, _ := new(big.Float).SetString(vwap)
+		return tri.Update(product, v)
+	}
+
+	_, ok := update("ETH-USD", "1500")
+	assert.False(t, ok)
+	_, ok = update("SOL-USD", "30")
+	assert.False(t, ok)
+	// An empty window
+	_, ok = update("BTC-USD", "0")
+	assert.False(t, ok)
+	_, ok = update("BTC-USD", "20000")
+	assert.False(t, ok)
+
+	s, ok := update("ETH-BTC", "0.0753")
+	assert.True(t, ok)
+	assert.Equal(t, "0.0753", s.Direct.String())
+	assert.Equal(t, "0.075", s.Implied.String())
+	assert.Equal(t, "40.00", s.Bps.Text('f', 2))
+	assert.Equal(t, "direct=0.0753000000000000 implied=0.0750000000000000 spread=40.00bps", s.String())
+
+	// Any leg updates the spread
+	s, ok = update("BTC-USD", "20100")
+	assert.True(t, ok)
+	assert.Equal(t, "90.2", s.Bps.Text('f', 1))
+}
@@ -1,9 +1,11 @@
 package vwap
 
 import (
+	"errors"
 	"fmt"
 	"math/big"
 	"sync"
+	"time"
 
 	"github.com/felipeblassioli/vwap/pkg/ringbuf"
 )
@@ -18,21 +20,59 @@ type Calculator struct {
 	// for the computation of the current VWAP.
 	windowWidth int
 
-	// pqs holds all Price x Quantity values used so far for the calculation of
-	// the current VWAP.
-	pqs *ringbuf.RingBuffer[*big.Float]
+	// trades holds the trades used for the calculation of the current VWAP:
+	// their prices, quantities, Price x Quantity values, sides and times, as
+	// well as the values of the enabled options.
+	trades *tradeWindow
 
 	// cumulativeTypicalPrice is the summation of all prices multiplied by the
 	// quantity of the traded asset used for the calculation of the current VWAP.
 	cumulativeTypicalPrice *big.Float
 
-	// qs holds all quantities used for the calculation of the current VWAP.
-	qs *ringbuf.RingBuffer[*big.Float]
-
 	// cumulativeVolume is the summation of all quantities used for the
 	// calculation of the current VWAP
 	cumulativeVolume *big.Float
 
+	// newest is the time of the last trade received.
+	newest time.Time
+
+	// maxAge, if positive, discards trades older than maxAge relative to the
+	// newest trade. See WithMaxAge.
+	maxAge time.Duration
+
+	// maxVolume, if not nil, discards the oldest trades, or part of them,
+	// exceeding maxVolume. See WithVolumeWindow.
+	maxVolume *big.Float
+
+	// cumulativeSquaredTypicalPrice is the summation of the Price² x Quantity
+	// values of the trades, used for the calculation of the current standard
+	// deviation. It is nil unless bands are enabled.
+	cumulativeSquaredTypicalPrice *big.Float
+
+	// bands holds the standard deviation multipliers of the bands reported
+	// in every Result. See WithBands.
+	bands []float64
+
+	// buys and sells are the running sums of each aggressor side. They are
+	// nil unless the side split is enabled. See WithSideSplit.
+	buys  *sideTotals
+	sells *sideTotals
+
+	// prices orders the prices of the trades for the calculation of the
+	// current percentiles. It is nil unless percentiles are enabled. See
+	// WithPercentiles.
+	prices      *orderStats
+	percentiles []float64
+
+	// profile is the volume profile of the trades. It is nil unless the
+	// volume profile is enabled. See WithVolumeProfile.
+	profile *VolumeProfile
+
+	// highs and lows track the highest and lowest prices of the trades in
+	// the window. They are nil unless the range is enabled. See WithRange.
+	highs *ringbuf.MonotonicDeque[*big.Float]
+	lows  *ringbuf.MonotonicDeque[*big.Float]
+
 	// vwap is the result of the VWAP calculation for `windowWidth`
 	// (Price, Quantity) pairs.
 	vwap *big.Float
@@ -53,6 +93,30 @@ const (
 
 var (
 	ErrFloatParse = floatParseError{msg: "failed to parse value into float"}
+
+	// ErrInvalidWindow is returned by NewCalculator for a non-positive window
+	// width.
+	ErrInvalidWindow = errors.New("window width must be positive")
+
+	// ErrNonPositivePrice and ErrNonPositiveSize are returned by Update for
+	// trades that cannot take part in a VWAP calculation.
+	ErrNonPositivePrice = errors.New("price must be positive")
+	ErrNonPositiveSize  = errors.New("size must be positive")
+
+	// ErrNotFinite is returned by Update for infinite prices or sizes.
+	ErrNotFinite = errors.New("value must be finite")
+
+	// ErrUnknownSide is returned by Update for trades without a known Side
+	// when the side split is enabled.
+	ErrUnknownSide = errors.New("trade side must be buy or sell")
+
+	// ErrInvalidBand is returned by NewCalculator for band multipliers that
+	// are not positive and finite.
+	ErrInvalidBand = errors.New("band multiplier must be positive and finite")
+
+	// ErrInvalidPercentile is returned by NewCalculator for percentiles out
+	// of the [0, 100] range.
+	ErrInvalidPercentile = errors.New("percentile must be between 0 and 100")
 )
 
 type floatParseError struct {
@@ -66,50 +130,478 @@ func (e floatParseError) Error() string { return e.msg }
 // Value returns the string that failed to be parsed into a big.Float
 func (e floatParseError) Value() string { return e.value }
 
-func NewCalculator(windowWidth int) *Calculator {
-	return &Calculator{
+// Trade is a single (Price, Quantity) data point fed to the Calculator.
+type Trade struct {
+	// Price and Size are decimal strings, e.g. "22386.79" and "0.0006".
+	Price string
+	Size  string
+	// Time is when the trade happened. It is reported back in the Result
+	// and may be left zero if the caller does not need it.
+	Time time.Time
+	// Side is the aggressor side of the trade. It is only required if the
+	// Calculator was created WithSideSplit.
+	Side Side
+	// TradeID is the exchange's ID of the trade. It is kept with the trade
+	// in the window and may be left zero.
+	TradeID int
+}
+
+// Result is the state of the Calculator's sliding window after an update.
+//
+// The big.Float values are copies owned by the caller.
+type Result struct {
+	// VWAP is the volume-weighted average price of the trades in the window.
+	VWAP *big.Float
+	// Volume is the summation of the sizes of the trades in the window.
+	Volume *big.Float
+	// Count is the number of trades in the window.
+	Count int
+	// Oldest and Newest are the times of the oldest and newest trades in
+	// the window.
+	Oldest time.Time
+	Newest time.Time
+	// Full reports whether the window holds windowWidth trades, i.e. whether
+	// the next update will slide the window.
+	Full bool
+	// StdDev is the volume-weighted standard deviation of the prices in the
+	// window and Bands the bands around VWAP built from it. Both are only
+	// set if the Calculator was created WithBands.
+	StdDev *big.Float
+	Bands  []Band
+	// Buy and Sell are the VWAPs of the buy-aggressor and sell-aggressor
+	// trades in the window and Imbalance the net order-flow imbalance, from
+	// -1 (only sellers) to 1 (only buyers). They are only set if the
+	// Calculator was created WithSideSplit.
+	Buy       *SideResult
+	Sell      *SideResult
+	Imbalance *big.Float
+	// Percentiles are the volume-weighted percentiles of the prices in the
+	// window, in the order they were configured. They are only set if the
+	// Calculator was created WithPercentiles.
+	Percentiles []Percentile
+	// High and Low are the highest and lowest prices of the trades in the
+	// window, or zero if it is empty. They are only set if the Calculator
+	// was created WithRange.
+	High *big.Float
+	Low  *big.Float
+}
+
+// String formats the VWAP with the precision of a float64.
+func (r Result) String() string {
+	return r.VWAP.Text('f', numPrecDigits)
+}
+
+// NewCalculator creates a Calculator with a sliding window of windowWidth
+// trades. It returns ErrInvalidWindow if windowWidth is not positive.
+func NewCalculator(windowWidth int, opts ...Option) (*Calculator, error) {
+	if windowWidth <= 0 {
+		return nil, fmt.Errorf("%w: %d", ErrInvalidWindow, windowWidth)
+	}
+
+	c := &Calculator{
 		windowWidth:            windowWidth,
-		pqs:                    ringbuf.NewRingBuffer[*big.Float](windowWidth),
+		trades:                 newTradeWindow(windowWidth),
 		cumulativeTypicalPrice: new(big.Float).SetPrec(prec).SetMode(mode),
-		qs:                     ringbuf.NewRingBuffer[*big.Float](windowWidth),
 		cumulativeVolume:       new(big.Float).SetPrec(prec).SetMode(mode),
 		vwap:                   new(big.Float).SetPrec(prec).SetMode(mode),
 	}
+	for _, opt := range opts {
+		if err := opt(c); err != nil {
+			return nil, err
+		}
+	}
+
+	return c, nil
 }
 
-// Update receives a pair of (Price, Quantity) and calculates the new VWAP
-// value. If the number of pairs used for the calculation so far exceeds the
-// windowWidth, it discards the oldest pair from the calculation and substitutes
-// it by the received pair.
-func (c *Calculator) Update(price, quantity string) (string, error) {
-	p, ok := new(big.Float).SetPrec(prec).SetMode(mode).SetString(price)
+// parse parses a price or size, rejecting values that are not positive and
+// finite.
+func parse(s string, errNonPositive error) (*big.Float, error) {
+	f, ok := new(big.Float).SetPrec(prec).SetMode(mode).SetString(s)
 	if !ok {
-		return "", fmt.Errorf("%w: %s", ErrFloatParse, price)
+		return nil, fmt.Errorf("%w: %s", ErrFloatParse, s)
+	}
+	if f.IsInf() {
+		return nil, fmt.Errorf("%w: %s", ErrNotFinite, s)
+	}
+	if f.Sign() <= 0 {
+		return nil, fmt.Errorf("%w: %s", errNonPositive, s)
 	}
 
-	q, ok := new(big.Float).SetPrec(prec).SetMode(mode).SetString(quantity)
-	if !ok {
-		return "", fmt.Errorf("%w %s", ErrFloatParse, quantity)
+	return f, nil
+}
+
+// Update receives a Trade and calculates the new VWAP value. If the number of
+// trades used for the calculation so far exceeds the windowWidth, it discards
+// the oldest trade from the calculation and substitutes it by the received
+// one. If the Calculator was created WithMaxAge or WithVolumeWindow, trades
+// older than the maximum age or exceeding the maximum volume are discarded
+// as well.
+//
+// Trades with an unparsable, infinite or non-positive price or size are
+// rejected with an error and leave the window untouched.
+func (c *Calculator) Update(t Trade) (Result, error) {
+	pt, err := c.parseTrade(t)
+	if err != nil {
+		return Result{}, err
 	}
-	pq := new(big.Float).SetPrec(prec).SetMode(mode).Mul(p, q)
 
+	return c.update(pt), nil
+}
+
+// update adds a trade parsed by parseTrade to the window and returns the new
+// Result.
+func (c *Calculator) update(pt parsedTrade) Result {
 	c.mu.Lock()
-	if c.pqs.Len() == c.windowWidth {
-		oldPQ := c.pqs.PopFront()
-		c.cumulativeTypicalPrice.Sub(c.cumulativeTypicalPrice, oldPQ)
+	defer c.mu.Unlock()
+	c.push(pt)
+	c.updateVWAP()
 
-		oldQ := c.qs.PopFront()
-		c.cumulativeVolume = c.cumulativeVolume.Sub(c.cumulativeVolume, oldQ)
+	return c.result()
+}
+
+// UpdateBatch updates the window with every trade, in order, as if Update
+// was called for each of them, and returns the Result after the last one.
+//
+// All trades are validated before any of them is applied, so an invalid
+// trade leaves the window untouched. The window is locked once for the whole
+// batch and the VWAP is only calculated at the end.
+func (c *Calculator) UpdateBatch(trades []Trade) (Result, error) {
+	pts, err := c.parseTrades(trades)
+	if err != nil {
+		return Result{}, err
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for _, pt := range pts {
+		c.push(pt)
+	}
+	c.updateVWAP()
+
+	return c.result(), nil
+}
+
+// UpdateBatchResults is like UpdateBatch but returns the Result after every
+// trade.
+func (c *Calculator) UpdateBatchResults(trades []Trade) ([]Result, error) {
+	pts, err := c.parseTrades(trades)
+	if err != nil {
+		return nil, err
+	}
+
+	rs := make([]Result, 0, len(pts))
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for _, pt := range pts {
+		c.push(pt)
+		c.updateVWAP()
+		rs = append(rs, c.result())
+	}
+
+	return rs, nil
+}
+
+// parsedTrade is a Trade validated and parsed by parseTrade.
+type parsedTrade struct {
+	p, q, pq *big.Float
+	side     Side
+	time     time.Time
+	id       int
+	// bucket is the volume profile bucket of the trade, if the volume
+	// profile is enabled.
+	bucket int64
+}
+
+// parseTrade validates and parses a trade. Trades with an unparsable,
+// infinite or non-positive price or size, without a side when the side
+// split is enabled, or with a price out of range of the volume profile, are
+// rejected.
+func (c *Calculator) parseTrade(t Trade) (parsedTrade, error) {
+	p, err := parse(t.Price, ErrNonPositivePrice)
+	if err != nil {
+		return parsedTrade{}, err
 	}
 
-	c.cumulativeTypicalPrice.Add(c.cumulativeTypicalPrice, pq)
-	c.pqs.PushBack(pq)
+	q, err := parse(t.Size, ErrNonPositiveSize)
+	if err != nil {
+		return parsedTrade{}, err
+	}
+
+	if c.buys != nil && t.Side != Buy && t.Side != Sell {
+		return parsedTrade{}, fmt.Errorf("%w: %s", ErrUnknownSide, t.Side)
+	}
+
+	var bucket int64
+	if c.profile != nil {
+		if bucket, err = c.profile.bucket(t.Price, p); err != nil {
+			return parsedTrade{}, err
+		}
+	}
+
+	return parsedTrade{
+		p:      p,
+		q:      q,
+		pq:     new(big.Float).SetPrec(prec).SetMode(mode).Mul(p, q),
+		side:   t.Side,
+		time:   t.Time,
+		id:     t.TradeID,
+		bucket: bucket,
+	}, nil
+}
+
+// parseTrades parses every trade, failing on the first invalid one.
+func (c *Calculator) parseTrades(trades []Trade) ([]parsedTrade, error) {
+	pts := make([]parsedTrade, 0, len(trades))
+	for i, t := range trades {
+		pt, err := c.parseTrade(t)
+		if err != nil {
+			return nil, fmt.Errorf("trade %d: %w", i, err)
+		}
+		pts = append(pts, pt)
+	}
+
+	return pts, nil
+}
+
+// push adds a trade to the window, discarding the trades that no longer fit
+// in it. It does not update the VWAP. It must be called with c.mu held.
+func (c *Calculator) push(t parsedTrade) {
+	// A trade received out of order and already older than the max age is
+	// discarded as it would be right after being added.
+	if c.maxAge > 0 && t.time.Before(c.newest.Add(-c.maxAge)) {
+		return
+	}
+
+	if c.trades.Full() {
+		c.evictOldest()
+	}
 
-	c.cumulativeVolume.Add(c.cumulativeVolume, q)
-	c.qs.PushBack(q)
+	w := c.trades
+	i := w.push()
+	w.ps[i].Set(t.p)
+	w.qs[i].Set(t.q)
+	w.pqs[i].Set(t.pq)
+	w.sides[i] = t.side
+	w.ts[i] = t.time
+	w.ids[i] = t.id
 
-	c.vwap.Quo(c.cumulativeTypicalPrice, c.cumulativeVolume)
-	c.mu.Unlock()
+	c.cumulativeTypicalPrice.Add(c.cumulativeTypicalPrice, t.pq)
+	c.cumulativeVolume.Add(c.cumulativeVolume, t.q)
+
+	if c.cumulativeSquaredTypicalPrice != nil {
+		p2q := w.p2qs[i].SetPrec(prec).SetMode(mode).Mul(t.pq, t.p)
+		c.cumulativeSquaredTypicalPrice.Add(c.cumulativeSquaredTypicalPrice, p2q)
+	}
+
+	if c.buys != nil {
+		c.sideTotals(t.side).add(t.pq, t.q)
+	}
+
+	if c.prices != nil {
+		c.prices.add(t.p, t.q)
+	}
+
+	if c.profile != nil {
+		w.buckets[i] = t.bucket
+		c.profile.add(w.buckets[i], t.q)
+	}
+
+	if c.highs != nil {
+		c.highs.Push(t.p)
+		c.lows.Push(t.p)
+	}
+
+	// An out-of-order trade does not move the newest time, and so the
+	// max age cutoff, backwards.
+	if t.time.After(c.newest) {
+		c.newest = t.time
+	}
+	c.evictOverBudget()
+}
+
+// evictOverBudget discards the trades older than c.maxAge relative to the
+// newest trade, and the oldest trades, or part of them, exceeding
+// c.maxVolume. It must be called with c.mu held.
+func (c *Calculator) evictOverBudget() {
+	if c.maxAge > 0 {
+		w := c.trades
+		cutoff := c.newest.Add(-c.maxAge)
+		for w.Len() > 0 && w.ts[w.front()].Before(cutoff) {
+			c.evictOldest()
+		}
+	}
+
+	if c.maxVolume != nil {
+		c.evictExcessVolume()
+	}
+}
+
+// updateVWAP calculates the VWAP from the running sums. It must be called
+// with c.mu held.
+func (c *Calculator) updateVWAP() {
+	// Quo of 0/0 panics with big.ErrNaN, so an empty window is defined as
+	// having a zero VWAP.
+	if c.cumulativeVolume.Sign() == 0 {
+		c.vwap.SetInt64(0)
+	} else {
+		c.vwap.Quo(c.cumulativeTypicalPrice, c.cumulativeVolume)
+	}
+}
+
+// evictOldest discards the oldest trade from the calculation.
+// It must be called with c.mu held and a non-empty window.
+func (c *Calculator) evictOldest() {
+	w := c.trades
+	i := w.popFront()
+	oldPQ, oldQ := &w.pqs[i], &w.qs[i]
+
+	c.cumulativeTypicalPrice.Sub(c.cumulativeTypicalPrice, oldPQ)
+	c.cumulativeVolume.Sub(c.cumulativeVolume, oldQ)
+
+	if c.cumulativeSquaredTypicalPrice != nil {
+		c.cumulativeSquaredTypicalPrice.Sub(c.cumulativeSquaredTypicalPrice, &w.p2qs[i])
+	}
+
+	if c.buys != nil {
+		c.sideTotals(w.sides[i]).sub(oldPQ, oldQ)
+	}
+
+	if c.prices != nil {
+		c.prices.sub(&w.ps[i], oldQ, 1)
+	}
+
+	if c.profile != nil {
+		c.profile.sub(w.buckets[i], oldQ, 1)
+	}
+
+	if c.highs != nil {
+		c.highs.PopFront()
+		c.lows.PopFront()
+	}
+}
+
+// evictExcessVolume discards the oldest trades until the window volume does
+// not exceed c.maxVolume. The last trade to be discarded is discarded only
+// partially if that suffices, i.e. its size is reduced by the excess volume
+// and its Price x Quantity values are scaled accordingly.
+//
+// It must be called with c.mu held.
+func (c *Calculator) evictExcessVolume() {
+	excess := new(big.Float).SetPrec(prec).SetMode(mode)
+	for c.cumulativeVolume.Cmp(c.maxVolume) > 0 {
+		excess.Sub(c.cumulativeVolume, c.maxVolume)
+
+		w := c.trades
+		i := w.front()
+		oldQ := &w.qs[i]
+		if oldQ.Cmp(excess) <= 0 {
+			c.evictOldest()
+			continue
+		}
+
+		// The oldest trade is shrunk in place.
+		ratio := new(big.Float).SetPrec(prec).SetMode(mode).Quo(excess, oldQ)
+
+		oldPQ := &w.pqs[i]
+		pq := new(big.Float).SetPrec(prec).SetMode(mode).Mul(oldPQ, ratio)
+		oldPQ.Sub(oldPQ, pq)
+		c.cumulativeTypicalPrice.Sub(c.cumulativeTypicalPrice, pq)
+
+		if c.cumulativeSquaredTypicalPrice != nil {
+			oldP2Q := &w.p2qs[i]
+			p2q := new(big.Float).SetPrec(prec).SetMode(mode).Mul(oldP2Q, ratio)
+			oldP2Q.Sub(oldP2Q, p2q)
+			c.cumulativeSquaredTypicalPrice.Sub(c.cumulativeSquaredTypicalPrice, p2q)
+		}
+
+		if c.buys != nil {
+			c.sideTotals(w.sides[i]).shrink(pq, excess)
+		}
+
+		if c.prices != nil {
+			c.prices.sub(&w.ps[i], excess, 0)
+		}
+
+		if c.profile != nil {
+			c.profile.sub(w.buckets[i], excess, 0)
+		}
+
+		oldQ.Sub(oldQ, excess)
+		// The window volume is exactly maxVolume now, which also stops
+		// rounding errors from looping again.
+		c.cumulativeVolume.Set(c.maxVolume)
+	}
+}
+
+// sideTotals returns the running sums of side s.
+func (c *Calculator) sideTotals(s Side) *sideTotals {
+	if s == Buy {
+		return c.buys
+	}
+
+	return c.sells
+}
+
+// Value returns the current state of the window without updating it.
+//
+// The Result of an empty window has zero VWAP, Volume and Count and zero
+// times.
+func (c *Calculator) Value() Result {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	return c.result()
+}
+
+// VolumeProfile returns the volume profile of the trades in the window. It
+// is the zero Profile unless the Calculator was created WithVolumeProfile.
+func (c *Calculator) VolumeProfile() Profile {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.profile == nil {
+		return Profile{}
+	}
+
+	return c.profile.profile()
+}
+
+// result must be called with c.mu held.
+func (c *Calculator) result() Result {
+	r := Result{
+		VWAP:   new(big.Float).Set(c.vwap),
+		Volume: new(big.Float).Set(c.cumulativeVolume),
+		Count:  c.trades.Len(),
+		Full:   c.trades.Full(),
+	}
+	if r.Count > 0 {
+		r.Oldest = c.trades.ts[c.trades.front()]
+		r.Newest = c.newest
+	}
+	if c.cumulativeSquaredTypicalPrice != nil {
+		r.StdDev = c.stdDev()
+		r.Bands = c.bandsAround(r.StdDev)
+	}
+	if c.buys != nil {
+		r.Buy = c.buys.result()
+		r.Sell = c.sells.result()
+		r.Imbalance = imbalance(r.Buy, r.Sell)
+	}
+	if c.prices != nil {
+		r.Percentiles = make([]Percentile, 0, len(c.percentiles))
+		for _, p := range c.percentiles {
+			r.Percentiles = append(r.Percentiles, Percentile{P: p, Price: c.prices.percentile(p)})
+		}
+	}
+	if c.highs != nil {
+		r.High = new(big.Float).SetPrec(prec).SetMode(mode)
+		r.Low = new(big.Float).SetPrec(prec).SetMode(mode)
+		if r.Count > 0 {
+			r.High.Set(c.highs.Front())
+			r.Low.Set(c.lows.Front())
+		}
+	}
 
-	return c.vwap.Text('f', numPrecDigits), nil
+	return r
 }
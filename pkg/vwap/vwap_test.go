@@ -1,12 +1,41 @@
 package vwap
 
 import (
+	"encoding/json"
 	"math/big"
+	"os"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/assert"
+
+	"github.com/felipeblassioli/vwap/pkg/coinbase"
 )
 
+func TestNewCalculator(t *testing.T) {
+	t.Run("Invalid window width", func(t *testing.T) {
+		for _, ww := range []int{0, -1} {
+			calc, err := NewCalculator(ww)
+
+			assert.Nil(t, calc)
+			assert.ErrorIs(t, err, ErrInvalidWindow)
+		}
+	})
+
+	t.Run("Empty window", func(t *testing.T) {
+		calc, err := NewCalculator(1)
+		assert.Nil(t, err)
+
+		r := calc.Value()
+		assert.Equal(t, 0, r.VWAP.Sign())
+		assert.Equal(t, 0, r.Volume.Sign())
+		assert.Equal(t, 0, r.Count)
+		assert.True(t, r.Oldest.IsZero())
+		assert.True(t, r.Newest.IsZero())
+		assert.False(t, r.Full)
+	})
+}
+
 func TestCalculator_Update(t *testing.T) {
 	t.Run("Data point parse failure", func(t *testing.T) {
 		tests := []struct {
@@ -23,14 +52,44 @@ func TestCalculator_Update(t *testing.T) {
 
 		const irrelevant = 1
 		for _, tc := range tests {
-			calc := NewCalculator(irrelevant)
+			calc, _ := NewCalculator(irrelevant)
 
-			_, err := calc.Update(tc.price, tc.quantity)
+			_, err := calc.Update(Trade{Price: tc.price, Size: tc.quantity})
 
 			assert.ErrorIs(t, err, ErrFloatParse)
 		}
 	})
 
+	t.Run("Invalid data points", func(t *testing.T) {
+		tests := []struct {
+			price    string
+			quantity string
+			err      error
+		}{
+			{"-1", "3.14159265359", ErrNonPositivePrice},
+			{"0", "3.14159265359", ErrNonPositivePrice},
+			{"-0", "3.14159265359", ErrNonPositivePrice},
+			{"3.14159265359", "-1", ErrNonPositiveSize},
+			{"3.14159265359", "0", ErrNonPositiveSize},
+			{"Inf", "3.14159265359", ErrNotFinite},
+			{"3.14159265359", "+Inf", ErrNotFinite},
+			{"-Inf", "3.14159265359", ErrNotFinite},
+		}
+
+		for _, tc := range tests {
+			calc, _ := NewCalculator(2)
+			calc.Update(Trade{Price: "1", Size: "2"})
+
+			_, err := calc.Update(Trade{Price: tc.price, Size: tc.quantity})
+
+			assert.ErrorIs(t, err, tc.err, "(%s, %s)", tc.price, tc.quantity)
+			// The window is left untouched
+			r := calc.Value()
+			assert.Equal(t, 1, r.Count)
+			assert.Equal(t, "1", r.VWAP.String())
+		}
+	})
+
 	t.Run("VWAP Calculation", func(t *testing.T) {
 		tests := []struct {
 			name        string
@@ -82,17 +141,163 @@ func TestCalculator_Update(t *testing.T) {
 
 		for _, tc := range tests {
 			t.Run(tc.name, func(t *testing.T) {
-				calc := NewCalculator(tc.windowWidth)
+				calc, _ := NewCalculator(tc.windowWidth)
 
 				for i := 0; i < len(tc.prices); i++ {
-					vwap, _ := calc.Update(tc.prices[i], tc.quantities[i])
+					r, _ := calc.Update(Trade{Price: tc.prices[i], Size: tc.quantities[i]})
 
 					exp, _ := new(big.Float).SetPrec(prec).SetMode(mode).SetString(tc.vwaps[i])
-					act, _ := new(big.Float).SetPrec(prec).SetMode(mode).SetString(vwap)
 
-					assert.True(t, exp.Cmp(act) == 0, "VWAPs %v !== %v", vwap, tc.vwaps[i])
+					assert.True(t, exp.Cmp(r.VWAP) == 0, "VWAPs %v !== %v", r, tc.vwaps[i])
 				}
 			})
 		}
 	})
+
+	t.Run("Window metadata", func(t *testing.T) {
+		calc, _ := NewCalculator(2)
+		t0 := time.Date(2022, 9, 12, 14, 28, 52, 0, time.UTC)
+
+		r, _ := calc.Update(Trade{Price: "1", Size: "2", Time: t0})
+		assert.Equal(t, 1, r.Count)
+		assert.Equal(t, "2", r.Volume.String())
+		assert.Equal(t, t0, r.Oldest)
+		assert.Equal(t, t0, r.Newest)
+		assert.False(t, r.Full)
+
+		r, _ = calc.Update(Trade{Price: "2", Size: "3", Time: t0.Add(time.Second)})
+		assert.Equal(t, 2, r.Count)
+		assert.Equal(t, "5", r.Volume.String())
+		assert.Equal(t, t0, r.Oldest)
+		assert.Equal(t, t0.Add(time.Second), r.Newest)
+		assert.True(t, r.Full)
+		assert.Equal(t, "1.6000000000000001", r.String())
+
+		r, _ = calc.Update(Trade{Price: "3", Size: "5", Time: t0.Add(2 * time.Second)})
+		assert.Equal(t, 2, r.Count)
+		assert.Equal(t, "8", r.Volume.String())
+		assert.Equal(t, t0.Add(time.Second), r.Oldest)
+		assert.True(t, r.Full)
+	})
+
+	t.Run("Result is not modified by later updates", func(t *testing.T) {
+		calc, _ := NewCalculator(1)
+
+		r, _ := calc.Update(Trade{Price: "1", Size: "2"})
+		calc.Update(Trade{Price: "3", Size: "5"})
+
+		assert.Equal(t, "1", r.VWAP.String())
+		assert.Equal(t, "2", r.Volume.String())
+	})
+}
+
+func TestCalculator_UpdateBatch(t *testing.T) {
+	trades := loadTrades(t)
+
+	t.Run("Same as sequential updates", func(t *testing.T) {
+		var (
+			seq, _   = NewCalculator(50, WithBands(1), WithSideSplit())
+			batch, _ = NewCalculator(50, WithBands(1), WithSideSplit())
+			all, _   = NewCalculator(50, WithBands(1), WithSideSplit())
+		)
+
+		var exp []Result
+		for _, trade := range trades {
+			r, err := seq.Update(trade)
+			assert.Nil(t, err)
+			exp = append(exp, r)
+		}
+
+		// Split in batches of different sizes, including an empty one
+		var (
+			act     []Result
+			batches = [][]Trade{trades[:1], trades[1:1], trades[1:70], trades[70:]}
+		)
+		for _, trades := range batches {
+			r, err := batch.UpdateBatch(trades)
+			assert.Nil(t, err)
+
+			rs, err := all.UpdateBatchResults(trades)
+			assert.Nil(t, err)
+			assert.Len(t, rs, len(trades))
+			act = append(act, rs...)
+
+			if len(rs) > 0 {
+				assert.Equal(t, rs[len(rs)-1], r)
+			}
+		}
+
+		assert.Equal(t, exp, act)
+		assert.Equal(t, seq.Value(), batch.Value())
+	})
+
+	t.Run("Invalid trade leaves the window untouched", func(t *testing.T) {
+		calc, _ := NewCalculator(50)
+		calc.Update(trades[0])
+
+		_, err := calc.UpdateBatch([]Trade{trades[1], {Price: "1", Size: "-1"}, trades[2]})
+		assert.ErrorIs(t, err, ErrNonPositiveSize)
+
+		_, err = calc.UpdateBatchResults([]Trade{trades[1], {Price: "x", Size: "1"}})
+		assert.ErrorIs(t, err, ErrFloatParse)
+
+		assert.Equal(t, 1, calc.Value().Count)
+	})
+}
+
+func Benchmark_Update(b *testing.B) {
+	trades := loadTrades(b)
+	b.ResetTimer()
+
+	for i := 0; i < b.N; i++ {
+		calc, _ := NewCalculator(50)
+		for _, trade := range trades {
+			calc.Update(trade)
+		}
+	}
+}
+
+func Benchmark_UpdateBatch(b *testing.B) {
+	trades := loadTrades(b)
+	b.ResetTimer()
+
+	for i := 0; i < b.N; i++ {
+		calc, _ := NewCalculator(50)
+		calc.UpdateBatch(trades)
+	}
+}
+
+func Benchmark_UpdateBatchResults(b *testing.B) {
+	trades := loadTrades(b)
+	b.ResetTimer()
+
+	for i := 0; i < b.N; i++ {
+		calc, _ := NewCalculator(50)
+		calc.UpdateBatchResults(trades)
+	}
+}
+
+// loadTrades loads the trades of the coinbase package's matches fixture.
+func loadTrades(tb testing.TB) []Trade {
+	tb.Helper()
+
+	b, err := os.ReadFile("../coinbase/testdata/matches-1.json")
+	if err != nil {
+		tb.Fatal(err)
+	}
+	var matches []coinbase.Match
+	if err := json.Unmarshal(b, &matches); err != nil {
+		tb.Fatal(err)
+	}
+
+	trades := make([]Trade, 0, len(matches))
+	for _, m := range matches {
+		side := Buy
+		if m.TakerSide() == "sell" {
+			side = Sell
+		}
+		trades = append(trades, Trade{Price: m.Price, Size: m.Size, Time: m.Time, Side: side})
+	}
+
+	return trades
 }
@@ -0,0 +1,143 @@
+package vwap
+
+import (
+	"math/big"
+	"time"
+)
+
+// minWindowSize is the number of trades a tradeWindow makes room for when
+// it first grows.
+const minWindowSize = 64
+
+// tradeWindow holds the trades of a Calculator's window in FIFO order as a
+// struct of arrays: the fields of the trade in slot i are at index i of
+// parallel arrays, rather than in objects of their own. The big.Float values
+// are stored in place and reused as the window slides, so that a full window
+// allocates nothing for them.
+//
+// The arrays double as the window fills, up to its width, so that wide
+// windows hold no more memory than the trades in them need.
+type tradeWindow struct {
+	width int
+
+	start int
+	count int
+
+	ps    []big.Float
+	qs    []big.Float
+	pqs   []big.Float
+	sides []Side
+	ts    []time.Time
+	ids   []int
+
+	// p2qs holds the Price² x Quantity values of the trades, and buckets
+	// their volume profile buckets. They are nil unless enabled.
+	p2qs    []big.Float
+	buckets []int64
+}
+
+func newTradeWindow(width int) *tradeWindow {
+	return &tradeWindow{width: width}
+}
+
+// withP2Q enables holding the Price² x Quantity values of the trades.
+func (w *tradeWindow) withP2Q() {
+	if w.p2qs == nil {
+		w.p2qs = make([]big.Float, len(w.qs))
+	}
+}
+
+// withBuckets enables holding the volume profile buckets of the trades.
+func (w *tradeWindow) withBuckets() {
+	if w.buckets == nil {
+		w.buckets = make([]int64, len(w.qs))
+	}
+}
+
+// Len returns the number of trades in the window.
+func (w *tradeWindow) Len() int {
+	return w.count
+}
+
+// Full reports whether the window holds width trades.
+func (w *tradeWindow) Full() bool {
+	return w.count == w.width
+}
+
+// slot returns the slot of the i-th trade of the window, counting from the
+// oldest one.
+func (w *tradeWindow) slot(i int) int {
+	return (w.start + i) % len(w.qs)
+}
+
+// front returns the slot of the oldest trade. The window must not be empty.
+func (w *tradeWindow) front() int {
+	return w.start
+}
+
+// push makes room for a trade at the back of the window and returns its
+// slot, whose fields the caller sets. The window must not be full.
+func (w *tradeWindow) push() int {
+	if w.count == len(w.qs) {
+		w.grow()
+	}
+	i := w.slot(w.count)
+	w.count++
+
+	return i
+}
+
+// popFront removes the oldest trade from the window and returns its slot,
+// whose fields are valid until the next push. The window must not be empty.
+func (w *tradeWindow) popFront() int {
+	i := w.start
+	w.start = (w.start + 1) % len(w.qs)
+	w.count--
+
+	return i
+}
+
+// reset empties the window, keeping its arrays.
+func (w *tradeWindow) reset() {
+	w.start, w.count = 0, 0
+}
+
+// grow doubles the size of the arrays, up to the width of the window, moving
+// the trades to the front of them.
+func (w *tradeWindow) grow() {
+	size := 2 * len(w.qs)
+	if size < minWindowSize {
+		size = minWindowSize
+	}
+	if size > w.width {
+		size = w.width
+	}
+
+	w.ps = regrow(w.ps, size, w.start, w.count)
+	w.qs = regrow(w.qs, size, w.start, w.count)
+	w.pqs = regrow(w.pqs, size, w.start, w.count)
+	w.sides = regrow(w.sides, size, w.start, w.count)
+	w.ts = regrow(w.ts, size, w.start, w.count)
+	w.ids = regrow(w.ids, size, w.start, w.count)
+	if w.p2qs != nil {
+		w.p2qs = regrow(w.p2qs, size, w.start, w.count)
+	}
+	if w.buckets != nil {
+		w.buckets = regrow(w.buckets, size, w.start, w.count)
+	}
+	w.start = 0
+}
+
+// regrow returns a copy of the count elements of the ring s starting at
+// start, at the front of a new slice of size elements.
+//
+// Copied big.Float values share their mantissa with the original ones, which
+// must not be used anymore.
+func regrow[T any](s []T, size, start, count int) []T {
+	grown := make([]T, size)
+	// The elements wrap around the end of s at most once.
+	n := copy(grown[:count], s[start:])
+	copy(grown[n:count], s[:count-n])
+
+	return grown
+}
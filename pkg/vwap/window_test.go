@@ -0,0 +1,86 @@
+package vwap
+
+import (
+	"fmt"
+	"math/big"
+	"runtime"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestTradeWindow(t *testing.T) {
+	w := newTradeWindow(100)
+	w.withP2Q()
+
+	prices := func() []string {
+		ps := make([]string, 0, w.Len())
+		for j := 0; j < w.Len(); j++ {
+			ps = append(ps, w.ps[w.slot(j)].String())
+		}
+		return ps
+	}
+
+	// Wrap around before growing
+	for p := 0; p < minWindowSize; p++ {
+		w.ps[w.push()].SetInt64(int64(p))
+	}
+	w.popFront()
+	w.popFront()
+	for p := minWindowSize; p < minWindowSize+4; p++ {
+		i := w.push()
+		w.ps[i].SetInt64(int64(p))
+		w.p2qs[i].SetInt64(int64(p))
+	}
+
+	assert.Equal(t, 100, len(w.qs))
+	assert.Equal(t, 100, len(w.p2qs))
+	assert.Nil(t, w.buckets)
+	assert.Equal(t, minWindowSize+2, w.Len())
+	assert.Equal(t, "2", prices()[0])
+	assert.Equal(t, fmt.Sprint(minWindowSize+3), prices()[w.Len()-1])
+	assert.Equal(t, fmt.Sprint(minWindowSize+3), w.p2qs[w.slot(w.Len()-1)].String())
+
+	for !w.Full() {
+		w.push()
+	}
+	assert.Equal(t, 100, w.Len())
+
+	// Slots are reused in place
+	i := w.popFront()
+	old := &w.ps[i]
+	assert.Equal(t, "2", old.String())
+	assert.Equal(t, i, w.push())
+	assert.Same(t, old, &w.ps[i])
+	w.ps[i].Set(big.NewFloat(1.5))
+	assert.Equal(t, "1.5", prices()[w.Len()-1])
+}
+
+// Benchmark_Window measures the throughput of a full window sliding, and
+// the memory it holds per trade, reported as B/trade.
+func Benchmark_Window(b *testing.B) {
+	trades := loadTrades(b)
+
+	for _, width := range []int{200, 10_000, 1_000_000} {
+		b.Run(fmt.Sprintf("width=%d", width), func(b *testing.B) {
+			var before, after runtime.MemStats
+			runtime.GC()
+			runtime.ReadMemStats(&before)
+
+			calc, _ := NewCalculator(width, WithBands(1), WithSideSplit())
+			for i := 0; i < width; i++ {
+				calc.Update(trades[i%len(trades)])
+			}
+
+			runtime.GC()
+			runtime.ReadMemStats(&after)
+
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				calc.Update(trades[i%len(trades)])
+			}
+			// Reported after ResetTimer, which discards metrics.
+			b.ReportMetric(float64(after.HeapAlloc-before.HeapAlloc)/float64(width), "B/trade")
+		})
+	}
+}